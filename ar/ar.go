@@ -2,6 +2,7 @@ package ar
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,10 +11,25 @@ import (
 	"time"
 )
 
+// filename: 16
+// modification: 12
+// owner: 6
+// gid: 6
+// filemode: 8
+// size: 10
+// trailer: 2
+const headerSize = 60
+
 type Reader struct {
-	r              io.Reader
-	lastFileReader io.Reader
-	skipByte       bool
+	r         io.Reader
+	member    *memberReader
+	skipByte  bool
+	longNames []byte
+
+	// offset is the absolute offset of the next byte to be read from r
+	offset int64
+	// index is the index of the next member header
+	index int
 }
 
 type FileInfo struct {
@@ -27,90 +43,282 @@ type FileInfo struct {
 	Reader io.Reader
 }
 
+// ReadSignature reads the archive signature from r, returning a *MagicError if it is wrong
+func ReadSignature(r io.Reader) error {
+	sig := make([]byte, len(Signature))
+	read, err := io.ReadFull(r, sig)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &MagicError{Got: sig[:read]}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive signature: %w", err)
+	}
+	if string(sig) != Signature {
+		return &MagicError{Got: sig}
+	}
+	return nil
+}
+
+// NewReader reads the members of an archive from r, which must be positioned just after
+// the signature (see ReadSignature). Offsets in errors are relative to the start of the signature.
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
-		r: r,
+		r:      r,
+		offset: int64(len(Signature)),
 	}
 }
 
 func (a *Reader) ReadFile() (*FileInfo, error) {
+	for {
+		fi, err := a.readFile()
+		if err != nil {
+			return nil, err
+		}
+		if fi.Name != gnuNameTable {
+			return fi, nil
+		}
+
+		// GNU extended name table, referenced by later members as "/<offset>"
+		var names bytes.Buffer
+		if _, err := io.Copy(&names, fi.Reader); err != nil {
+			return nil, fmt.Errorf("failed to read extended name table: %w", err)
+		}
+		a.longNames = names.Bytes()
+	}
+}
+
+func (a *Reader) readFile() (*FileInfo, error) {
 	// scan to the end of the previous file, if any
-	if a.lastFileReader != nil {
-		_, err := io.Copy(io.Discard, a.lastFileReader)
+	if a.member != nil {
+		_, err := io.Copy(io.Discard, a.member)
 		if err != nil {
 			return nil, err
 		}
-		a.lastFileReader = nil
 	}
 	if a.skipByte {
 		b := make([]byte, 1)
-		if bytesRead, err := a.r.Read(b); err != nil {
+		if _, err := io.ReadFull(a.r, b); err == io.EOF {
+			// some writers don't pad the last member
+			return nil, io.EOF
+		} else if err != nil {
 			return nil, fmt.Errorf("failed to skip to even byte alignment: %w", err)
-		} else if bytesRead != 1 {
-			return nil, fmt.Errorf("failed to skip to even byte alignment: empty read")
 		}
+		if b[0] != '\n' {
+			return nil, &PaddingError{
+				Position:      a.member.pos,
+				Header:        a.member.header,
+				PaddingOffset: a.offset,
+				Got:           b[0],
+			}
+		}
+		a.offset++
+		a.skipByte = false
 	}
+	a.member = nil
 
-	// filename: 16
-	// modification: 12
-	// owner: 6
-	// gid: 6
-	// filemode: 8
-	// size: 10
-	// trailer: 2
-	const headerSize = 60
-	var fileHeader bytes.Buffer
-	read, err := io.Copy(&fileHeader, io.LimitReader(a.r, headerSize))
+	pos := Position{
+		Index:  a.index,
+		Offset: a.offset,
+	}
+	header := make([]byte, headerSize)
+	read, err := io.ReadFull(a.r, header)
+	a.offset += int64(read)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, &TruncatedError{
+			Position: pos,
+			Header:   header[:read],
+			Expected: headerSize,
+			Read:     int64(read),
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file header: %w", err)
 	}
-	if read == 0 {
-		return nil, io.EOF
+
+	fi, pos, err := parseHeader(header, pos)
+	if err != nil {
+		return nil, err
 	}
-	if read != headerSize {
-		return nil, fmt.Errorf("failed to read complete file header, expected %d bytes, got %d bytes", headerSize, read)
+	a.index++
+
+	a.member = &memberReader{
+		a:         a,
+		pos:       pos,
+		header:    header,
+		size:      fi.Size,
+		remaining: fi.Size,
+	}
+	fi.Reader = a.member
+
+	// file records start on even bytes only, so when reading the next file we need to skip one byte
+	a.skipByte = fi.Size&1 == 1
+
+	if err := resolveName(fi, pos, header, a.longNames); err != nil {
+		return nil, err
 	}
 
+	return fi, nil
+}
+
+// memberReader reads the contents of the current member, returning a *TruncatedError
+// if the archive ends early
+type memberReader struct {
+	a         *Reader
+	pos       Position
+	header    []byte
+	size      int64
+	remaining int64
+}
+
+func (m *memberReader) Read(b []byte) (int, error) {
+	if m.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > m.remaining {
+		b = b[:m.remaining]
+	}
+	n, err := m.a.r.Read(b)
+	m.remaining -= int64(n)
+	m.a.offset += int64(n)
+	if (err == io.EOF || err == io.ErrUnexpectedEOF) && m.remaining > 0 {
+		return n, &TruncatedError{
+			Position: m.pos,
+			Header:   m.header,
+			Expected: m.size,
+			Read:     m.size - m.remaining,
+		}
+	}
+	return n, err
+}
+
+// parseHeader parses a 60 byte member header, returning the raw name for resolveName in the position
+func parseHeader(header []byte, pos Position) (*FileInfo, Position, error) {
 	var fi FileInfo
 
-	fi.Name = strings.TrimSuffix(strings.TrimSpace(string(fileHeader.Bytes()[0:16])), "/")
+	pos.Name = strings.TrimSpace(string(header[0:16]))
 
-	mod, err := strconv.ParseInt(strings.TrimSpace(string(fileHeader.Bytes()[16:16+12])), 10, 64)
+	fieldError := func(field string, value []byte, err error) error {
+		return &FieldError{
+			Position: pos,
+			Header:   header,
+			Field:    field,
+			Value:    strings.TrimSpace(string(value)),
+			Err:      err,
+		}
+	}
+
+	// the GNU name table leaves everything but the size blank
+	mod, err := parseOptionalNumber(header[16:16+12], 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file mod: %w", err)
+		return nil, pos, fieldError("mod", header[16:16+12], err)
 	}
 	fi.Mod = time.Unix(mod, 0)
 
-	fi.Owner, err = strconv.Atoi(strings.TrimSpace(string(fileHeader.Bytes()[28 : 28+6])))
+	owner, err := parseOptionalNumber(header[28:28+6], 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file owner: %w", err)
+		return nil, pos, fieldError("owner", header[28:28+6], err)
 	}
+	fi.Owner = int(owner)
 
-	fi.Group, err = strconv.Atoi(strings.TrimSpace(string(fileHeader.Bytes()[34 : 34+6])))
+	group, err := parseOptionalNumber(header[34:34+6], 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file group: %w", err)
+		return nil, pos, fieldError("group", header[34:34+6], err)
 	}
+	fi.Group = int(group)
 
-	mode, err := strconv.ParseInt(strings.TrimSpace(string(fileHeader.Bytes()[40:40+8])), 8, 32)
+	mode, err := parseOptionalNumber(header[40:40+8], 8, 32)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file mode: %w", err)
+		return nil, pos, fieldError("mode", header[40:40+8], err)
 	}
 	fi.Mode = os.FileMode(mode)
 
-	fi.Size, err = strconv.ParseInt(strings.TrimSpace(string(fileHeader.Bytes()[48:48+10])), 10, 64)
+	fi.Size, err = strconv.ParseInt(strings.TrimSpace(string(header[48:48+10])), 10, 64)
+	if err == nil && fi.Size < 0 {
+		err = errors.New("negative size")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file size: %w", err)
+		return nil, pos, fieldError("size", header[48:48+10], err)
 	}
 
-	if !bytes.Equal(fileHeader.Bytes()[58:58+2], []byte{0x60, 0x0a}) {
-		return nil, fmt.Errorf("invalid file info trailer for %q (got %x)", fi.Name, fileHeader.Bytes()[58:58+2])
+	if !bytes.Equal(header[58:58+2], []byte{0x60, 0x0a}) {
+		return nil, pos, &TrailerError{
+			Position: pos,
+			Header:   header,
+		}
 	}
 
-	fi.Reader = io.LimitReader(a.r, fi.Size)
-	a.lastFileReader = fi.Reader
+	return &fi, pos, nil
+}
 
-	// file records start on even bytes only, so when reading the next file we need to skip one byte
-	a.skipByte = fi.Size&1 == 1
+// parseOptionalNumber parses a space padded header field, treating a blank field as zero
+func parseOptionalNumber(field []byte, base int, bitSize int) (int64, error) {
+	value := strings.TrimSpace(string(field))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, base, bitSize)
+}
+
+const (
+	gnuSymbolTable   = "/"
+	gnuSymbol64Table = "/SYM64/"
+	gnuNameTable     = "//"
+	bsdNamePrefix    = "#1/"
+)
+
+// resolveName sets fi.Name from the raw header name in pos, handling GNU "/<offset>"
+// references into the extended name table and BSD "#1/<len>" inline names
+func resolveName(fi *FileInfo, pos Position, header []byte, longNames []byte) error {
+	rawName := pos.Name
+	nameError := func(err error) error {
+		return &FieldError{
+			Position: pos,
+			Header:   header,
+			Field:    "name",
+			Value:    rawName,
+			Err:      err,
+		}
+	}
 
-	return &fi, nil
+	switch {
+	case rawName == gnuSymbolTable || rawName == gnuSymbol64Table || rawName == gnuNameTable:
+		fi.Name = rawName
+	case strings.HasPrefix(rawName, bsdNamePrefix):
+		nameLen, err := strconv.ParseInt(strings.TrimPrefix(rawName, bsdNamePrefix), 10, 64)
+		if err != nil {
+			return nameError(fmt.Errorf("failed to read BSD name length: %w", err))
+		}
+		if nameLen < 0 || nameLen > fi.Size {
+			return nameError(fmt.Errorf("invalid BSD name length %d for file of size %d", nameLen, fi.Size))
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(fi.Reader, name); err != nil {
+			return err
+		}
+		// the name is counted in the member size but is not part of the file
+		fi.Size -= nameLen
+		fi.Name = strings.TrimRight(string(name), "\x00")
+	case strings.HasPrefix(rawName, "/") && len(rawName) > 1:
+		offset, err := strconv.Atoi(rawName[1:])
+		if err != nil {
+			return nameError(fmt.Errorf("failed to read extended name offset: %w", err))
+		}
+		if longNames == nil {
+			return nameError(errors.New("extended name used without a name table"))
+		}
+		if offset < 0 || offset >= len(longNames) {
+			return nameError(fmt.Errorf("extended name offset %d outside name table of %d bytes", offset, len(longNames)))
+		}
+		name := longNames[offset:]
+		if end := bytes.IndexByte(name, '\n'); end != -1 {
+			name = name[:end]
+		}
+		fi.Name = strings.TrimSuffix(string(name), "/")
+	default:
+		fi.Name = strings.TrimSuffix(rawName, "/")
+	}
+	return nil
 }
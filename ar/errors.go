@@ -0,0 +1,96 @@
+package ar
+
+import (
+	"fmt"
+)
+
+// Position locates a member within an archive
+type Position struct {
+	// Index is the zero based index of the member, counting symbol and name tables
+	Index int
+	// Name is the name from the member header, before long names are resolved.
+	// It is empty when the header itself could not be read.
+	Name string
+	// Offset is the absolute byte offset of the member header
+	Offset int64
+}
+
+func (p Position) String() string {
+	if p.Name == "" {
+		return fmt.Sprintf("member %d at offset %d", p.Index, p.Offset)
+	}
+	return fmt.Sprintf("member %d %q at offset %d", p.Index, p.Name, p.Offset)
+}
+
+// MagicError is returned when an archive does not start with Signature
+type MagicError struct {
+	Got []byte
+}
+
+func (e *MagicError) Error() string {
+	return fmt.Sprintf("bad ar archive signature (got %q)", e.Got)
+}
+
+// TrailerError is returned when a member header does not end with "`\n"
+type TrailerError struct {
+	Position
+	Header []byte
+}
+
+func (e *TrailerError) Error() string {
+	trailer := e.Header
+	if len(trailer) > 58 {
+		trailer = trailer[58:]
+	}
+	return fmt.Sprintf("invalid file info trailer for %s (got %x)", e.Position, trailer)
+}
+
+// FieldError is returned when a member header field can't be parsed
+type FieldError struct {
+	Position
+	Header []byte
+	Field  string
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("failed to read file %s %q for %s: %s", e.Field, e.Value, e.Position, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// TruncatedError is returned when the archive ends part way through a member header or its contents
+type TruncatedError struct {
+	Position
+	// Header holds the header bytes read, which is all of them when the contents were truncated
+	Header []byte
+	// Expected and Read are byte counts for the header or contents, whichever was truncated
+	Expected int64
+	Read     int64
+}
+
+func (e *TruncatedError) Error() string {
+	if len(e.Header) < headerSize {
+		return fmt.Sprintf("truncated file header for %s, expected %d bytes, got %d bytes", e.Position, e.Expected, e.Read)
+	}
+	return fmt.Sprintf("truncated contents for %s, expected %d bytes, got %d bytes", e.Position, e.Expected, e.Read)
+}
+
+// PaddingError is returned when the byte after an odd sized member isn't the "\n" padding byte,
+// which usually means the member size is wrong
+type PaddingError struct {
+	// Position is that of the member preceding the padding
+	Position
+	// Header is the header of the member preceding the padding, whose size is likely wrong
+	Header []byte
+	// PaddingOffset is the absolute offset of the padding byte
+	PaddingOffset int64
+	Got           byte
+}
+
+func (e *PaddingError) Error() string {
+	return fmt.Sprintf("misaligned padding after %s: expected 0x0a at offset %d, got %#02x", e.Position, e.PaddingOffset, e.Got)
+}
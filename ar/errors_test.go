@@ -0,0 +1,195 @@
+package ar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testArchive writes an archive of members with the given contents, named a, b, c and so on
+func testArchive(t *testing.T, contents ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i, data := range contents {
+		fi := &FileInfo{Name: string(rune('a' + i)), Mode: 0o100644, Size: int64(len(data)), Reader: strings.NewReader(data)}
+		if err := w.WriteFile(fi); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readAll reads every member of data and its contents with Reader, returning the first error
+func readAll(data []byte) error {
+	r := bytes.NewReader(data)
+	if err := ReadSignature(r); err != nil {
+		return err
+	}
+	archive := NewReader(r)
+	for {
+		fi, err := archive.ReadFile()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, fi.Reader); err != nil {
+			return err
+		}
+	}
+}
+
+// indexAll reads data with OpenReaderAt
+func indexAll(data []byte) error {
+	_, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	return err
+}
+
+func TestErrors(t *testing.T) {
+	// the first member header is at offset 8, straight after the signature
+	const first = int64(len(Signature))
+	second := first + headerSize + 4
+
+	for _, test := range []struct {
+		name  string
+		data  func(t *testing.T) []byte
+		check func(t *testing.T, err error)
+	}{
+		{
+			name: "bad signature",
+			data: func(t *testing.T) []byte { return []byte("!<arch>X" + strings.Repeat(" ", 60)) },
+			check: func(t *testing.T, err error) {
+				var e *MagicError
+				if !errors.As(err, &e) || string(e.Got) != "!<arch>X" {
+					t.Errorf("expected a MagicError, got %v", err)
+				}
+			},
+		},
+		{
+			name: "short signature",
+			data: func(t *testing.T) []byte { return []byte("!<ar") },
+			check: func(t *testing.T, err error) {
+				var e *MagicError
+				if !errors.As(err, &e) || string(e.Got) != "!<ar" {
+					t.Errorf("expected a MagicError, got %v", err)
+				}
+			},
+		},
+		{
+			name: "bad trailer",
+			data: func(t *testing.T) []byte {
+				data := testArchive(t, "abcd", "efgh")
+				data[second+58] = 'X'
+				return data
+			},
+			check: func(t *testing.T, err error) {
+				var e *TrailerError
+				if !errors.As(err, &e) {
+					t.Fatalf("expected a TrailerError, got %v", err)
+				}
+				if e.Index != 1 || e.Offset != second || e.Name != "b" || len(e.Header) != headerSize {
+					t.Errorf("unexpected %+v", e)
+				}
+			},
+		},
+		{
+			name: "bad mode",
+			data: func(t *testing.T) []byte {
+				data := testArchive(t, "abcd")
+				copy(data[first+40:], "99999999")
+				return data
+			},
+			check: func(t *testing.T, err error) {
+				var e *FieldError
+				if !errors.As(err, &e) {
+					t.Fatalf("expected a FieldError, got %v", err)
+				}
+				if e.Index != 0 || e.Offset != first || e.Field != "mode" || e.Value != "99999999" {
+					t.Errorf("unexpected %+v", e)
+				}
+			},
+		},
+		{
+			name: "truncated header",
+			data: func(t *testing.T) []byte { return testArchive(t, "abcd", "efgh")[:second+30] },
+			check: func(t *testing.T, err error) {
+				var e *TruncatedError
+				if !errors.As(err, &e) {
+					t.Fatalf("expected a TruncatedError, got %v", err)
+				}
+				if e.Index != 1 || e.Offset != second || e.Expected != headerSize || e.Read != 30 || len(e.Header) != 30 {
+					t.Errorf("unexpected %+v", e)
+				}
+			},
+		},
+		{
+			name: "truncated contents",
+			data: func(t *testing.T) []byte { return testArchive(t, "abcd", "efghij")[:second+headerSize+2] },
+			check: func(t *testing.T, err error) {
+				var e *TruncatedError
+				if !errors.As(err, &e) {
+					t.Fatalf("expected a TruncatedError, got %v", err)
+				}
+				if e.Index != 1 || e.Offset != second || e.Name != "b" || e.Expected != 6 || e.Read != 2 || len(e.Header) != headerSize {
+					t.Errorf("unexpected %+v", e)
+				}
+			},
+		},
+		{
+			name: "bad padding",
+			data: func(t *testing.T) []byte {
+				data := testArchive(t, "abc", "efgh")
+				data[first+headerSize+3] = 'X'
+				return data
+			},
+			check: func(t *testing.T, err error) {
+				var e *PaddingError
+				if !errors.As(err, &e) {
+					t.Fatalf("expected a PaddingError, got %v", err)
+				}
+				if e.Index != 0 || e.Offset != first || e.Name != "a" || e.PaddingOffset != first+headerSize+3 || e.Got != 'X' {
+					t.Errorf("unexpected %+v", e)
+				}
+				if !bytes.HasPrefix(e.Header, []byte("a ")) || len(e.Header) != headerSize {
+					t.Errorf("padding error has header %q, want the first member's", e.Header)
+				}
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.data(t)
+			for _, read := range []struct {
+				name string
+				read func([]byte) error
+			}{
+				{"Reader", readAll},
+				{"OpenReaderAt", indexAll},
+			} {
+				err := read.read(data)
+				if err == nil {
+					t.Fatalf("%s succeeded", read.name)
+				}
+				if err.Error() == "" {
+					t.Errorf("%s returned an empty message", read.name)
+				}
+				test.check(t, err)
+			}
+		})
+	}
+}
+
+func TestTrailerErrorShortHeader(t *testing.T) {
+	for _, header := range [][]byte{nil, []byte("short"), bytes.Repeat([]byte{' '}, 58)} {
+		e := &TrailerError{Header: header}
+		if !strings.Contains(e.Error(), "invalid file info trailer") {
+			t.Errorf("unexpected message %q", e.Error())
+		}
+	}
+}
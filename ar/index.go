@@ -0,0 +1,139 @@
+package ar
+
+import (
+	"fmt"
+	"io"
+)
+
+// Member is the location of one archive member within an Index
+type Member struct {
+	FileInfo
+
+	// HeaderOffset is the absolute offset of the member's 60 byte header
+	HeaderOffset int64
+	// Offset is the absolute offset of the member's contents, after any BSD inline name
+	Offset int64
+}
+
+// Index holds the offset of every member of an ar archive, so members can be
+// opened in any order, or concurrently, without re-reading the archive
+type Index struct {
+	r       io.ReaderAt
+	Members []*Member
+}
+
+// OpenReaderAt scans the member headers of the ar archive in r once, including the signature.
+// FileInfo.Reader is left nil on the returned members, use Open or OpenMember instead.
+func OpenReaderAt(r io.ReaderAt, size int64) (*Index, error) {
+	if err := ReadSignature(io.NewSectionReader(r, 0, size)); err != nil {
+		return nil, err
+	}
+
+	idx := &Index{
+		r: r,
+	}
+	var longNames []byte
+
+	for i, offset := 0, int64(len(Signature)); offset < size; i++ {
+		pos := Position{
+			Index:  i,
+			Offset: offset,
+		}
+		header := make([]byte, headerSize)
+		read, err := r.ReadAt(header, offset)
+		if err == io.EOF && read < headerSize {
+			return nil, &TruncatedError{
+				Position: pos,
+				Header:   header[:read],
+				Expected: headerSize,
+				Read:     int64(read),
+			}
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read file header at offset %d: %w", offset, err)
+		}
+		fi, pos, err := parseHeader(header, pos)
+		if err != nil {
+			return nil, err
+		}
+
+		dataOffset := offset + headerSize
+		memberSize := fi.Size
+		if dataOffset+memberSize > size {
+			return nil, &TruncatedError{
+				Position: pos,
+				Header:   header,
+				Expected: memberSize,
+				Read:     size - dataOffset,
+			}
+		}
+
+		fi.Reader = io.NewSectionReader(r, dataOffset, memberSize)
+		if err := resolveName(fi, pos, header, longNames); err != nil {
+			return nil, err
+		}
+		fi.Reader = nil
+
+		// file records start on even bytes only
+		next := dataOffset + memberSize
+		if memberSize&1 == 1 && next < size {
+			pad := make([]byte, 1)
+			if _, err := r.ReadAt(pad, next); err != nil {
+				return nil, fmt.Errorf("failed to read padding at offset %d: %w", next, err)
+			}
+			if pad[0] != '\n' {
+				return nil, &PaddingError{
+					Position:      pos,
+					Header:        header,
+					PaddingOffset: next,
+					Got:           pad[0],
+				}
+			}
+			next++
+		}
+
+		if fi.Name == gnuNameTable {
+			longNames = make([]byte, memberSize)
+			if _, err := r.ReadAt(longNames, dataOffset); err != nil {
+				return nil, fmt.Errorf("failed to read extended name table: %w", err)
+			}
+			offset = next
+			continue
+		}
+
+		idx.Members = append(idx.Members, &Member{
+			FileInfo:     *fi,
+			HeaderOffset: offset,
+			// skip any BSD inline name
+			Offset: dataOffset + memberSize - fi.Size,
+		})
+		offset = next
+	}
+
+	return idx, nil
+}
+
+// Lookup returns the first member called name
+func (x *Index) Lookup(name string) (*Member, bool) {
+	for _, m := range x.Members {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// Open returns a reader for the contents of the first member called name
+func (x *Index) Open(name string) (*io.SectionReader, error) {
+	m, ok := x.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("no member %q in archive", name)
+	}
+	return x.OpenMember(m), nil
+}
+
+// OpenMember returns a reader for the contents of m. Each call returns an
+// independent reader, so members can be read concurrently.
+func (x *Index) OpenMember(m *Member) *io.SectionReader {
+	return io.NewSectionReader(x.r, m.Offset, m.Size)
+}
@@ -0,0 +1,164 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	bsdSymbolTable   = "__.SYMDEF"
+	bsdSymbol64Table = "__.SYMDEF_64"
+)
+
+// Symbol is an entry of an archive symbol table
+type Symbol struct {
+	Name string
+	// HeaderOffset is the absolute offset of the header of the member defining the symbol
+	HeaderOffset int64
+}
+
+// IsSymbolTable reports whether name is a GNU ("/", "/SYM64/") or BSD ("__.SYMDEF") symbol table member
+func IsSymbolTable(name string) bool {
+	switch strings.TrimSuffix(name, " SORTED") {
+	case gnuSymbolTable, gnuSymbol64Table, bsdSymbolTable, bsdSymbol64Table:
+		return true
+	}
+	return false
+}
+
+// ParseSymbolTable parses the contents of the symbol table member called name
+func ParseSymbolTable(name string, data []byte) ([]Symbol, error) {
+	switch strings.TrimSuffix(name, " SORTED") {
+	case gnuSymbolTable:
+		return parseGNUSymbolTable(data, 4)
+	case gnuSymbol64Table:
+		return parseGNUSymbolTable(data, 8)
+	case bsdSymbolTable:
+		return parseBSDSymbolTable(data, 4)
+	case bsdSymbol64Table:
+		return parseBSDSymbolTable(data, 8)
+	default:
+		return nil, fmt.Errorf("%q is not a symbol table", name)
+	}
+}
+
+// parseGNUSymbolTable reads a big-endian count, that many member offsets and then
+// that many NUL terminated names
+func parseGNUSymbolTable(data []byte, width int) ([]Symbol, error) {
+	count, err := readUint(data, 0, width, binary.BigEndian)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read symbol count: %w", err)
+	}
+	if count > uint64(len(data)/width) {
+		return nil, fmt.Errorf("symbol count %d too large for table of %d bytes", count, len(data))
+	}
+
+	symbols := make([]Symbol, count)
+	for i := range symbols {
+		offset, err := readUint(data, width*(i+1), width, binary.BigEndian)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read offset of symbol %d: %w", i, err)
+		}
+		symbols[i].HeaderOffset = int64(offset)
+	}
+
+	names := data[width*(len(symbols)+1):]
+	for i := range symbols {
+		end := bytes.IndexByte(names, 0)
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated name for symbol %d", i)
+		}
+		symbols[i].Name = string(names[:end])
+		names = names[end+1:]
+	}
+
+	return symbols, nil
+}
+
+// parseBSDSymbolTable reads the byte size of the ranlib array, the array of
+// (name offset, member offset) pairs, then the byte size of the string table and the
+// string table itself, all in little-endian byte order
+func parseBSDSymbolTable(data []byte, width int) ([]Symbol, error) {
+	ranlibSize, err := readUint(data, 0, width, binary.LittleEndian)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ranlib size: %w", err)
+	}
+	if ranlibSize%uint64(2*width) != 0 || ranlibSize > uint64(len(data)-width) {
+		return nil, fmt.Errorf("invalid ranlib size %d", ranlibSize)
+	}
+	ranlibs := data[width : width+int(ranlibSize)]
+
+	stringsOffset := width + int(ranlibSize)
+	stringsSize, err := readUint(data, stringsOffset, width, binary.LittleEndian)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read string table size: %w", err)
+	}
+	if stringsSize > uint64(len(data)-stringsOffset-width) {
+		return nil, fmt.Errorf("invalid string table size %d", stringsSize)
+	}
+	stringTable := data[stringsOffset+width : stringsOffset+width+int(stringsSize)]
+
+	symbols := make([]Symbol, len(ranlibs)/(2*width))
+	for i := range symbols {
+		nameOffset, _ := readUint(ranlibs, 2*width*i, width, binary.LittleEndian)
+		headerOffset, _ := readUint(ranlibs, 2*width*i+width, width, binary.LittleEndian)
+		if nameOffset >= uint64(len(stringTable)) {
+			return nil, fmt.Errorf("name offset %d of symbol %d outside string table", nameOffset, i)
+		}
+		name := stringTable[nameOffset:]
+		if end := bytes.IndexByte(name, 0); end != -1 {
+			name = name[:end]
+		}
+		symbols[i] = Symbol{
+			Name:         string(name),
+			HeaderOffset: int64(headerOffset),
+		}
+	}
+
+	return symbols, nil
+}
+
+func readUint(data []byte, offset int, width int, order binary.ByteOrder) (uint64, error) {
+	if offset < 0 || offset+width > len(data) {
+		return 0, errors.New("unexpected end of symbol table")
+	}
+	if width == 8 {
+		return order.Uint64(data[offset:]), nil
+	}
+	return uint64(order.Uint32(data[offset:])), nil
+}
+
+// Symbols returns a map of symbol name to the name of the member defining it,
+// built from every symbol table in the archive
+func (x *Index) Symbols() (map[string]string, error) {
+	byOffset := map[int64]string{}
+	for _, m := range x.Members {
+		byOffset[m.HeaderOffset] = m.Name
+	}
+
+	result := map[string]string{}
+	for _, m := range x.Members {
+		if !IsSymbolTable(m.Name) {
+			continue
+		}
+		data := make([]byte, m.Size)
+		if _, err := x.r.ReadAt(data, m.Offset); err != nil {
+			return nil, fmt.Errorf("failed to read symbol table %q: %w", m.Name, err)
+		}
+		symbols, err := ParseSymbolTable(m.Name, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse symbol table %q: %w", m.Name, err)
+		}
+		for _, sym := range symbols {
+			member, ok := byOffset[sym.HeaderOffset]
+			if !ok {
+				return nil, fmt.Errorf("symbol %q refers to unknown member at offset %d", sym.Name, sym.HeaderOffset)
+			}
+			result[sym.Name] = member
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,244 @@
+package ar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Signature is the global header every ar archive starts with
+const Signature = "!<arch>\n"
+
+// Writer writes ar archives readable by Reader (and by binutils ar / dpkg-deb).
+// Output depends only on the FileInfo values passed in, so callers wanting
+// deterministic archives should zero Mod, Owner and Group.
+type Writer struct {
+	w         io.Writer
+	wroteSig  bool
+	remaining int64
+	pad       bool
+	closed    bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: w,
+	}
+}
+
+// WriteHeader finishes the previous member, if any, and writes the header for fi.
+// Exactly fi.Size bytes must then be written with Write.
+func (a *Writer) WriteHeader(fi *FileInfo) error {
+	if a.closed {
+		return errors.New("ar writer is closed")
+	}
+	if err := a.finishFile(); err != nil {
+		return err
+	}
+	if err := a.writeSignature(); err != nil {
+		return err
+	}
+
+	header, err := formatHeader(fi)
+	if err != nil {
+		return err
+	}
+	if _, err := a.w.Write(header); err != nil {
+		return fmt.Errorf("failed to write file header: %w", err)
+	}
+
+	a.remaining = fi.Size
+	a.pad = fi.Size&1 == 1
+	return nil
+}
+
+// Write writes to the current member, returning an error if more than the
+// size given to WriteHeader is written.
+func (a *Writer) Write(b []byte) (int, error) {
+	if int64(len(b)) > a.remaining {
+		n, err := a.w.Write(b[:a.remaining])
+		a.remaining -= int64(n)
+		if err != nil {
+			return n, err
+		}
+		return n, errors.New("write too long for ar member")
+	}
+	n, err := a.w.Write(b)
+	a.remaining -= int64(n)
+	return n, err
+}
+
+// WriteFile writes the header for fi followed by fi.Size bytes from fi.Reader
+func (a *Writer) WriteFile(fi *FileInfo) error {
+	if err := a.WriteHeader(fi); err != nil {
+		return err
+	}
+	if fi.Size == 0 {
+		return nil
+	}
+	if fi.Reader == nil {
+		return fmt.Errorf("no reader for %q", fi.Name)
+	}
+	written, err := io.Copy(a, io.LimitReader(fi.Reader, fi.Size))
+	if err != nil {
+		return fmt.Errorf("failed to write %q: %w", fi.Name, err)
+	}
+	if written != fi.Size {
+		return fmt.Errorf("failed to write %q: expected %d bytes, got %d bytes", fi.Name, fi.Size, written)
+	}
+	return nil
+}
+
+// Close pads the last member and writes the signature if no members were written.
+// It does not close the underlying writer.
+func (a *Writer) Close() error {
+	if a.closed {
+		return nil
+	}
+	if err := a.finishFile(); err != nil {
+		return err
+	}
+	if err := a.writeSignature(); err != nil {
+		return err
+	}
+	a.closed = true
+	return nil
+}
+
+func (a *Writer) writeSignature() error {
+	if a.wroteSig {
+		return nil
+	}
+	if _, err := io.WriteString(a.w, Signature); err != nil {
+		return fmt.Errorf("failed to write archive signature: %w", err)
+	}
+	a.wroteSig = true
+	return nil
+}
+
+func (a *Writer) finishFile() error {
+	if a.remaining != 0 {
+		return fmt.Errorf("ar member is missing %d bytes", a.remaining)
+	}
+	if a.pad {
+		// file records start on even bytes only
+		if _, err := a.w.Write([]byte{'\n'}); err != nil {
+			return fmt.Errorf("failed to write padding byte: %w", err)
+		}
+		a.pad = false
+	}
+	return nil
+}
+
+func formatHeader(fi *FileInfo) ([]byte, error) {
+	if err := checkName(fi.Name); err != nil {
+		return nil, err
+	}
+	if fi.Size < 0 {
+		return nil, fmt.Errorf("invalid size %d for %q", fi.Size, fi.Name)
+	}
+	mode, err := headerMode(fi.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write mode for %q: %w", fi.Name, err)
+	}
+
+	var mod int64
+	if !fi.Mod.IsZero() {
+		mod = fi.Mod.Unix()
+	}
+
+	header := make([]byte, 0, headerSize)
+	for _, field := range []struct {
+		name  string
+		value string
+		width int
+	}{
+		{"name", fi.Name, 16},
+		{"modification", strconv.FormatInt(mod, 10), 12},
+		{"owner", strconv.Itoa(fi.Owner), 6},
+		{"group", strconv.Itoa(fi.Group), 6},
+		{"mode", strconv.FormatUint(uint64(mode), 8), 8},
+		{"size", strconv.FormatInt(fi.Size, 10), 10},
+	} {
+		header, err = appendField(header, field.value, field.width)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s for %q: %w", field.name, fi.Name, err)
+		}
+	}
+	header = append(header, 0x60, 0x0a)
+	return header, nil
+}
+
+// checkName returns an error for names Reader would read back differently: only short
+// names are written, so anything that looks like a GNU or BSD long name reference is refused
+func checkName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("ar member name is empty")
+	case strings.HasPrefix(name, "/"), strings.HasPrefix(name, bsdNamePrefix):
+		return fmt.Errorf("ar member name %q looks like a special member or long name reference", name)
+	case strings.HasSuffix(name, "/"):
+		return fmt.Errorf("ar member name %q ends with /, which ends names in headers", name)
+	case strings.TrimSpace(name) != name:
+		return fmt.Errorf("ar member name %q has surrounding space, which is padding in headers", name)
+	case strings.ContainsAny(name, "\n\x00"):
+		return fmt.Errorf("ar member name %q has a newline or NUL", name)
+	}
+	return nil
+}
+
+// headerMode returns the mode to write in a header. Modes read by Reader, which are stat
+// modes such as 0o100644, are written as they are, and modes with os.FileMode type and
+// special bits, e.g. from os.Stat, are converted to the stat equivalent.
+func headerMode(m os.FileMode) (uint32, error) {
+	if m&^0o177777 == 0 {
+		return uint32(m), nil
+	}
+
+	mode := uint32(m.Perm())
+	for flag, bits := range map[os.FileMode]uint32{
+		os.ModeSetuid: 0o4000,
+		os.ModeSetgid: 0o2000,
+		os.ModeSticky: 0o1000,
+	} {
+		if m&flag != 0 {
+			mode |= bits
+		}
+	}
+	switch m.Type() {
+	case 0:
+		mode |= 0o100000
+	case os.ModeDir:
+		mode |= 0o040000
+	case os.ModeSymlink:
+		mode |= 0o120000
+	case os.ModeNamedPipe:
+		mode |= 0o010000
+	case os.ModeSocket:
+		mode |= 0o140000
+	case os.ModeDevice:
+		mode |= 0o060000
+	case os.ModeDevice | os.ModeCharDevice:
+		mode |= 0o020000
+	default:
+		return 0, fmt.Errorf("mode %s has no stat equivalent", m)
+	}
+	if rest := m &^ (os.ModeType | os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky); rest != 0 {
+		return 0, fmt.Errorf("mode %s has no stat equivalent", m)
+	}
+	return mode, nil
+}
+
+func appendField(b []byte, value string, width int) ([]byte, error) {
+	if len(value) > width {
+		return nil, fmt.Errorf("%q does not fit in %d bytes", value, width)
+	}
+	b = append(b, value...)
+	for i := len(value); i < width; i++ {
+		b = append(b, ' ')
+	}
+	return b, nil
+}
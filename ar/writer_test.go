@@ -0,0 +1,131 @@
+package ar
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	members := []struct {
+		fi   FileInfo
+		data string
+		// mode is what Reader gives back, if different from fi.Mode
+		mode os.FileMode
+	}{
+		{fi: FileInfo{Name: "debian-binary", Mode: 0o100644}, data: "2.0\n"},
+		{fi: FileInfo{Name: "odd", Mod: time.Unix(1700000000, 0), Owner: 1000, Group: 100, Mode: 0o644}, data: "odd"},
+		{fi: FileInfo{Name: "empty", Mode: 0o100600}},
+		{fi: FileInfo{Name: "sixteen-chars-xx", Mode: 0o100755}, data: "x"},
+		{fi: FileInfo{Name: "with space.txt", Mode: 0o644}, data: "ab"},
+		{fi: FileInfo{Name: "dir", Mode: os.ModeDir | 0o755}, mode: 0o40755},
+		{fi: FileInfo{Name: "setuid", Mode: os.ModeSetuid | 0o755}, mode: 0o104755},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, m := range members {
+		fi := m.fi
+		fi.Size = int64(len(m.data))
+		fi.Reader = strings.NewReader(m.data)
+		if err := w.WriteFile(&fi); err != nil {
+			t.Fatalf("WriteFile(%q): %v", fi.Name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	if err := ReadSignature(r); err != nil {
+		t.Fatal(err)
+	}
+	ar := NewReader(r)
+	for _, m := range members {
+		fi, err := ar.ReadFile()
+		if err != nil {
+			t.Fatalf("ReadFile for %q: %v", m.fi.Name, err)
+		}
+		data, err := io.ReadAll(fi.Reader)
+		if err != nil {
+			t.Fatalf("reading %q: %v", m.fi.Name, err)
+		}
+
+		wantMode := m.fi.Mode
+		if m.mode != 0 {
+			wantMode = m.mode
+		}
+		var wantMod int64
+		if !m.fi.Mod.IsZero() {
+			wantMod = m.fi.Mod.Unix()
+		}
+		if fi.Name != m.fi.Name || fi.Mod.Unix() != wantMod || fi.Owner != m.fi.Owner ||
+			fi.Group != m.fi.Group || fi.Mode != wantMode || fi.Size != int64(len(m.data)) || string(data) != m.data {
+			t.Errorf("read back %q as name %q mod %d owner %d group %d mode %o size %d data %q",
+				m.fi.Name, fi.Name, fi.Mod.Unix(), fi.Owner, fi.Group, fi.Mode, fi.Size, data)
+		}
+	}
+	if _, err := ar.ReadFile(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last member, got %v", err)
+	}
+}
+
+func TestWriterEmptyArchive(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != Signature {
+		t.Errorf("got %q, want just the signature", buf.String())
+	}
+}
+
+func TestWriterRejects(t *testing.T) {
+	for _, fi := range []FileInfo{
+		{Name: ""},
+		{Name: "/"},
+		{Name: "//"},
+		{Name: "/12"},
+		{Name: "/SYM64/"},
+		{Name: "#1/20"},
+		{Name: "trailing/"},
+		{Name: " leading"},
+		{Name: "trailing "},
+		{Name: "new\nline"},
+		{Name: "nul\x00"},
+		{Name: "seventeen-chars-x"},
+		{Name: "negative", Size: -1},
+		{Name: "irregular", Mode: os.ModeIrregular | 0o644},
+		{Name: "append", Mode: os.ModeAppend | 0o644},
+		{Name: "owner", Owner: 10000000},
+	} {
+		fi := fi
+		if err := NewWriter(io.Discard).WriteHeader(&fi); err == nil {
+			t.Errorf("WriteHeader accepted %q (size %d, mode %s, owner %d)", fi.Name, fi.Size, fi.Mode, fi.Owner)
+		}
+	}
+}
+
+func TestWriterSizeMismatch(t *testing.T) {
+	w := NewWriter(io.Discard)
+	if err := w.WriteHeader(&FileInfo{Name: "short", Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("12345")); err == nil {
+		t.Error("Write accepted more than the member size")
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close after a full member: %v", err)
+	}
+
+	w = NewWriter(io.Discard)
+	if err := w.WriteHeader(&FileInfo{Name: "short", Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close accepted a member missing bytes")
+	}
+}
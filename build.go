@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/porty/deb-info/deb"
+)
+
+// buildCommand creates a package from a directory, like dpkg-deb --build
+func buildCommand(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	compression := fs.String("compression", "xz", "Compression for the control and data archives: gzip, xz, zstd or none")
+	mtime := fs.Int64("mtime", sourceDateEpoch(), "Clamp modification times to this Unix time, 0 for the newest in the tree (defaults to $SOURCE_DATE_EPOCH)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info build [-compression NAME] [-mtime SECONDS] DIR PACKAGE")
+		fmt.Fprintln(fs.Output(), "DIR holds the package's files, and a DEBIAN directory with the control file and maintainer scripts.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	opts := deb.BuildOptions{Compression: *compression}
+	if *mtime > 0 {
+		opts.ModTime = time.Unix(*mtime, 0)
+	}
+
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := deb.Build(fs.Arg(0), f, opts); err != nil {
+		f.Close()
+		os.Remove(fs.Arg(1))
+		return fmt.Errorf("failed to build package: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Built %s\n", fs.Arg(1))
+	return nil
+}
+
+// sourceDateEpoch returns $SOURCE_DATE_EPOCH, the reproducible builds timestamp, or 0 if it isn't set
+func sourceDateEpoch() int64 {
+	epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return epoch
+}
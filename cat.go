@@ -0,0 +1,325 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/porty/deb-info/deb"
+)
+
+// catCommand writes files from the data archive to standard output, in argument order
+func catCommand(args []string) error {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	gunzip := fs.Bool("gunzip", false, "Decompress gzipped files, e.g. changelog.gz")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info cat [-gunzip] PACKAGE PATH...")
+		fmt.Fprintln(fs.Output(), "PACKAGE can be a file, an HTTP(S) URL, or - for standard input.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	src := &catSource{name: fs.Arg(0)}
+	defer src.Close()
+
+	var requested []string
+	want := map[string]bool{}
+	for _, name := range fs.Args()[1:] {
+		name = deb.NormalizePath(name)
+		requested = append(requested, name)
+		want[name] = true
+	}
+	out := newCatOutput(os.Stdout, requested, *gunzip)
+	defer out.Close()
+
+	symlinks := map[string]string{}
+	hardLinks := map[string]string{}
+	regular := map[string]bool{}
+	found, err := catFiles(src, want, out, func(name string, hdr *tar.Header) {
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			regular[name] = true
+		case tar.TypeSymlink:
+			symlinks[name] = hdr.Linkname
+		case tar.TypeLink:
+			hardLinks[name] = deb.NormalizePath(hdr.Linkname)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// anything not found directly may be a link, to a file that has already been passed
+	problems := 0
+	again := map[string]bool{}
+	for i, name := range requested {
+		if found[name] {
+			continue
+		}
+		target, err := resolveLinks(name, symlinks, hardLinks)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			out.skip(i)
+			problems++
+		case !regular[target]:
+			fmt.Fprintf(os.Stderr, "%s: not a file in the package\n", name)
+			out.skip(i)
+			problems++
+		default:
+			out.files[i] = target
+			if !out.has(target) {
+				again[target] = true
+			}
+		}
+	}
+	if err := out.flush(); err != nil {
+		return err
+	}
+	if len(again) > 0 {
+		if _, err := catFiles(src, again, out, nil); err != nil {
+			return err
+		}
+	}
+
+	if problems > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// catFiles reads the data archive of the package, passing the regular files in want to out.
+// visit, if set, sees the normalized name of every entry, otherwise reading stops once all
+// of want is found.
+func catFiles(src *catSource, want map[string]bool, out *catOutput, visit func(name string, hdr *tar.Header)) (map[string]bool, error) {
+	pkg, closePkg, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer closePkg()
+
+	data, err := pkg.Data()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	found := map[string]bool{}
+	for visit != nil || len(found) < len(want) {
+		hdr, err := data.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := deb.NormalizePath(hdr.Name)
+		if visit != nil {
+			visit(name, hdr)
+		}
+		if !want[name] || hdr.Typeflag != tar.TypeReg || found[name] {
+			continue
+		}
+		found[name] = true
+		if err := out.add(name, data); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+	return found, nil
+}
+
+// catOutput writes files in the order they were asked for, whatever order the archive has
+// them in. Files that turn up before their turn are spooled to temporary files.
+type catOutput struct {
+	w      io.Writer
+	gunzip bool
+	// files is the file to write for each argument, which may be a link's target, or "" to skip it
+	files   []string
+	next    int
+	spooled map[string]*os.File
+}
+
+func newCatOutput(w io.Writer, files []string, gunzip bool) *catOutput {
+	return &catOutput{
+		w:       w,
+		gunzip:  gunzip,
+		files:   append([]string(nil), files...),
+		spooled: map[string]*os.File{},
+	}
+}
+
+// has reports whether name has been spooled, so can be written again without another pass
+func (o *catOutput) has(name string) bool {
+	return o.spooled[name] != nil
+}
+
+// skip leaves out argument i, which can't be written
+func (o *catOutput) skip(i int) {
+	o.files[i] = ""
+}
+
+// add takes the contents of name from r, writing it straight out if it is next and not
+// asked for again, and otherwise spooling it
+func (o *catOutput) add(name string, r io.Reader) error {
+	if o.has(name) {
+		return nil
+	}
+	if o.next < len(o.files) && o.files[o.next] == name && !containsString(o.files[o.next+1:], name) {
+		if err := copyFile(o.w, r, name, o.gunzip); err != nil {
+			return err
+		}
+		o.next++
+		return o.flush()
+	}
+
+	f, err := os.CreateTemp("", "deb-info-cat-*")
+	if err != nil {
+		return err
+	}
+	o.spooled[name] = f
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	return o.flush()
+}
+
+// flush writes out spooled files until one that hasn't been found yet is due
+func (o *catOutput) flush() error {
+	for ; o.next < len(o.files); o.next++ {
+		name := o.files[o.next]
+		if name == "" {
+			continue
+		}
+		f := o.spooled[name]
+		if f == nil {
+			return nil
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := copyFile(o.w, f, name, o.gunzip); err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Close removes the spooled files
+func (o *catOutput) Close() error {
+	for _, f := range o.spooled {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	return nil
+}
+
+// copyFile copies a file from the data archive, decompressing it if asked to and it looks gzipped
+func copyFile(w io.Writer, r io.Reader, name string, gunzip bool) error {
+	if !gunzip || !strings.HasSuffix(name, ".gz") {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		_, err := io.Copy(w, br)
+		return err
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	_, err = io.Copy(w, gz)
+	return err
+}
+
+// resolveLinks follows the package's symlinks, including in parent directories, and hard
+// links to find the file name refers to. Absolute symlinks are relative to the package root.
+func resolveLinks(name string, symlinks map[string]string, hardLinks map[string]string) (string, error) {
+	const maxSymlinks = 40
+	followed := 0
+	parts := strings.Split(name, "/")
+	var resolved []string
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+		resolved = append(resolved, part)
+		target, ok := symlinks[strings.Join(resolved, "/")]
+		if !ok {
+			continue
+		}
+		followed++
+		if followed > maxSymlinks {
+			return "", errors.New("too many levels of symbolic links")
+		}
+		// carry on from the directory holding the symlink, or the root for absolute targets
+		resolved = resolved[:len(resolved)-1]
+		if strings.HasPrefix(target, "/") {
+			resolved = nil
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+
+	name = strings.Join(resolved, "/")
+	if target, ok := hardLinks[name]; ok {
+		return target, nil
+	}
+	return name, nil
+}
+
+// catSource opens the package for each pass over it. Standard input can only be read once,
+// so it is copied to a temporary file as it is read.
+type catSource struct {
+	name  string
+	spool *os.File
+}
+
+func (s *catSource) Open() (*deb.Package, func() error, error) {
+	if s.name != "" && s.name != "-" {
+		return openPackage(s.name)
+	}
+	if s.spool != nil {
+		if _, err := s.spool.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		pkg, err := deb.Open(s.spool)
+		return pkg, func() error { return nil }, err
+	}
+	f, err := os.CreateTemp("", "deb-info-*.deb")
+	if err != nil {
+		return nil, nil, err
+	}
+	s.spool = f
+	pkg, err := deb.Open(io.TeeReader(os.Stdin, f))
+	return pkg, func() error { return nil }, err
+}
+
+func (s *catSource) Close() error {
+	if s.spool == nil {
+		return nil
+	}
+	s.spool.Close()
+	return os.Remove(s.spool.Name())
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/porty/deb-info/deb"
+	"github.com/porty/deb-info/version"
+)
+
+// compareVersionsCommand works like dpkg --compare-versions, exiting 0 if the relation holds and 1 if not
+func compareVersionsCommand(args []string) error {
+	fs := flag.NewFlagSet("compare-versions", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info compare-versions VERSION OP VERSION")
+		fmt.Fprintln(fs.Output(), "OP is one of lt le eq ne ge gt << <= = >= >>")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	ok, err := version.Satisfies(fs.Arg(0), fs.Arg(1), fs.Arg(2))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// satisfiesCommand reports whether a package satisfies a dependency, exiting 1 if it doesn't
+func satisfiesCommand(args []string) error {
+	fs := flag.NewFlagSet("satisfies", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info satisfies PACKAGE DEPENDENCY")
+		fmt.Fprintln(fs.Output(), `e.g. deb-info satisfies foo_1.2-1_amd64.deb "foo (>= 1.0) | bar"`)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	relations, err := deb.ParseRelations(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if len(relations) == 0 {
+		return errors.New("no dependency given")
+	}
+
+	pkg, closePkg, err := openPackage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closePkg()
+	control, err := pkg.ControlFields()
+	if err != nil {
+		return err
+	}
+
+	satisfied := true
+	for _, alternatives := range relations {
+		ok, err := alternatives.SatisfiedBy(control)
+		if err != nil {
+			return err
+		}
+		result := "satisfied"
+		if !ok {
+			result = "not satisfied"
+			satisfied = false
+		}
+		fmt.Fprintf(os.Stdout, "%s: %s by %s %s\n", alternatives, result, control.Package(), control.Version())
+	}
+
+	if !satisfied {
+		return exitStatus(1)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/porty/deb-info/deb"
+)
+
+type jsonControlMember struct {
+	Name    string `json:"name"`
+	Mode    string `json:"mode"`
+	Size    int64  `json:"size"`
+	Content string `json:"content"`
+}
+
+func controlMembersToJSON(pkg *deb.Package) []*jsonControlMember {
+	result := []*jsonControlMember{}
+	for _, m := range pkg.ControlMembers {
+		result = append(result, &jsonControlMember{
+			Name:    m.Name,
+			Mode:    m.Mode.String(),
+			Size:    m.Size,
+			Content: string(m.Data),
+		})
+	}
+	return result
+}
+
+func printControlMembers(w io.Writer, pkg *deb.Package) {
+	fmt.Fprintln(w, "Control archive")
+	tw := tabwriter.NewWriter(w, 10, 2, 4, ' ', 0)
+	tw.Write([]byte("Name\tMode\tSize\n"))
+	for _, m := range pkg.ControlMembers {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", m.Name, m.Mode.String(), m.Size)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+func printMaintainerScripts(w io.Writer, pkg *deb.Package) {
+	for _, m := range pkg.ControlMembers {
+		if !m.IsMaintainerScript() {
+			continue
+		}
+		fmt.Fprintf(w, "==> %s <==\n", m.Name)
+		w.Write(m.Data)
+		if len(m.Data) > 0 && m.Data[len(m.Data)-1] != '\n' {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printControlMember writes the contents of a single control member, e.g. a maintainer script
+func printControlMember(w io.Writer, pkg *deb.Package, name string) error {
+	m := pkg.ControlMember(name)
+	if m == nil {
+		return fmt.Errorf("control archive has no %q", name)
+	}
+	_, err := w.Write(m.Data)
+	return err
+}
@@ -0,0 +1,317 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/porty/deb-info/ar"
+	"github.com/porty/deb-info/deb822"
+	"github.com/porty/deb-info/version"
+)
+
+// BuildOptions controls Build
+type BuildOptions struct {
+	// Compression is used for both archives: "gzip", "xz", "zstd" or "none". Empty means xz, like dpkg-deb.
+	Compression string
+	// ModTime clamps modification times: anything newer is set to it, and it is used for the
+	// members of the package itself. If zero, the newest time in the tree is used.
+	ModTime time.Time
+}
+
+// Build creates a package from dir, which holds the package's files along with a DEBIAN
+// directory containing the control file and maintainer scripts, like dpkg-deb --build.
+// The output depends only on the tree: entries are sorted, owned by root:root and have
+// their modification times clamped to BuildOptions.ModTime.
+func Build(dir string, w io.Writer, opts BuildOptions) error {
+	if opts.Compression == "" {
+		opts.Compression = "xz"
+	}
+	c, err := findCompressor(opts.Compression)
+	if err != nil {
+		return err
+	}
+
+	controlFiles, err := readControlDir(filepath.Join(dir, "DEBIAN"))
+	if err != nil {
+		return err
+	}
+	dataFiles, err := readDataDir(dir)
+	if err != nil {
+		return err
+	}
+
+	b := &builder{modTime: opts.ModTime.Truncate(time.Second)}
+	if b.modTime.IsZero() {
+		for _, f := range append(controlFiles, dataFiles...) {
+			if f.info.ModTime().After(b.modTime) {
+				b.modTime = f.info.ModTime().Truncate(time.Second)
+			}
+		}
+	}
+
+	var control bytes.Buffer
+	if err := b.writeTar(&control, c, controlFiles); err != nil {
+		return fmt.Errorf("failed to write control archive: %w", err)
+	}
+
+	// the data archive size has to be known before it can be added to the package
+	data, err := os.CreateTemp("", "deb-info-data-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(data.Name())
+	defer data.Close()
+	if err := b.writeTar(data, c, dataFiles); err != nil {
+		return fmt.Errorf("failed to write data archive: %w", err)
+	}
+	dataSize, err := data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return writePackage(w, b.modTime,
+		&ar.FileInfo{Name: "control.tar" + c.ext, Size: int64(control.Len()), Reader: &control},
+		&ar.FileInfo{Name: "data.tar" + c.ext, Size: dataSize, Reader: data},
+	)
+}
+
+// writePackage writes the ar container of a package: debian-binary followed by the control
+// and data archives, which are given ownership and modes like dpkg-deb gives them
+func writePackage(w io.Writer, modTime time.Time, control *ar.FileInfo, data *ar.FileInfo) error {
+	aw := ar.NewWriter(w)
+	debianBinary := "2.0\n"
+	for _, fi := range []*ar.FileInfo{
+		{Name: "debian-binary", Size: int64(len(debianBinary)), Reader: strings.NewReader(debianBinary)},
+		control,
+		data,
+	} {
+		fi.Mod = modTime
+		fi.Owner = 0
+		fi.Group = 0
+		// written as octal like a stat mode, as dpkg-deb does
+		fi.Mode = 0o100644
+		if err := aw.WriteFile(fi); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+// buildFile is a file to be added to an archive
+type buildFile struct {
+	path string
+	// name is the name in the archive, e.g. "./usr/bin/foo"
+	name string
+	info fs.FileInfo
+}
+
+// readControlDir lists and checks the files of the DEBIAN directory
+func readControlDir(dir string) ([]*buildFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control directory: %w", err)
+	}
+	rootInfo, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*buildFile{{path: dir, name: "./", info: rootInfo}}
+	foundControl := false
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("control directory member %s is not a regular file", e.Name())
+		}
+		path := filepath.Join(dir, e.Name())
+		if isMaintainerScript(e.Name()) && info.Mode().Perm()&0o555 != 0o555 {
+			return nil, fmt.Errorf("maintainer script %s must be readable and executable, but has mode %s", e.Name(), info.Mode())
+		}
+		if e.Name() == "control" {
+			if err := checkControlFile(path); err != nil {
+				return nil, err
+			}
+			foundControl = true
+		}
+		files = append(files, &buildFile{path: path, name: "./" + e.Name(), info: info})
+	}
+	if !foundControl {
+		return nil, fmt.Errorf("control directory %s has no control file", dir)
+	}
+	return files, nil
+}
+
+func isMaintainerScript(name string) bool {
+	for _, script := range MaintainerScripts {
+		if name == script {
+			return true
+		}
+	}
+	return false
+}
+
+// checkControlFile checks the control file parses and has the fields dpkg needs, see checkControl
+func checkControlFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	control, err := ParseControl(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse control file: %w", err)
+	}
+	return checkControl(control)
+}
+
+// checkControl checks a control file has the fields dpkg needs
+func checkControl(control *deb822.Paragraph) error {
+	for _, field := range []string{"Package", "Version", "Architecture"} {
+		if control.Value(field) == "" {
+			return fmt.Errorf("control file has no %s field", field)
+		}
+	}
+	if _, err := version.Parse(control.Version()); err != nil {
+		return fmt.Errorf("control file has an invalid Version: %w", err)
+	}
+	return nil
+}
+
+// readDataDir lists the package's files, everything in dir apart from DEBIAN, parents first
+func readDataDir(dir string) ([]*buildFile, error) {
+	var files []*buildFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "DEBIAN" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		name := "./"
+		if rel != "." {
+			name += rel
+			if d.IsDir() {
+				name += "/"
+			}
+		}
+		files = append(files, &buildFile{path: path, name: name, info: info})
+		return nil
+	})
+	return files, err
+}
+
+type builder struct {
+	modTime time.Time
+}
+
+// writeTar writes files as a compressed tar stream
+func (b *builder) writeTar(w io.Writer, c *compressor, files []*buildFile) error {
+	cw, err := c.create(w, 0)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(cw)
+
+	// hard links are found by comparing files of the same size
+	bySize := map[int64][]*buildFile{}
+
+	for _, f := range files {
+		var link string
+		switch mode := f.info.Mode(); {
+		case mode.IsRegular(), mode.IsDir():
+		case mode&fs.ModeSymlink != 0:
+			link, err = os.Readlink(f.path)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: unsupported file type %s", f.path, mode.Type())
+		}
+
+		hdr, err := tar.FileInfoHeader(f.info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		hdr.Name = f.name
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "root", "root"
+		hdr.ModTime = b.clamp(f.info.ModTime())
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+		hdr.Format = tar.FormatGNU
+
+		if f.info.Mode().IsRegular() {
+			for _, other := range bySize[f.info.Size()] {
+				if os.SameFile(f.info, other.info) {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = other.name
+					hdr.Size = 0
+					break
+				}
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				bySize[f.info.Size()] = append(bySize[f.info.Size()], f)
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := copyFileTo(tw, f.path, hdr.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// clamp limits t to the build's modification time
+func (b *builder) clamp(t time.Time) time.Time {
+	if t.After(b.modTime) {
+		return b.modTime
+	}
+	return t.Truncate(time.Second)
+}
+
+func copyFileTo(w io.Writer, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(w, f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if n != size {
+		return fmt.Errorf("%s: %w", path, errors.New("file changed size while being added"))
+	}
+	return nil
+}
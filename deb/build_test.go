@@ -0,0 +1,285 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/porty/deb-info/ar"
+)
+
+const buildControl = "Package: built\nVersion: 1.0-1\nArchitecture: all\nMaintainer: A <a@example.com>\nDescription: test\n"
+
+// buildTreeFile is a file of a tree for Build, with mode 0 meaning 0644 for files and 0755 for directories
+type buildTreeFile struct {
+	name string
+	data string
+	mode os.FileMode
+	// link makes a symlink to link, hardLink a hard link to an earlier file
+	link, hardLink string
+}
+
+var buildTree = []buildTreeFile{
+	{name: "DEBIAN/", mode: 0o755},
+	{name: "DEBIAN/control", data: buildControl},
+	{name: "DEBIAN/postinst", data: "#!/bin/sh\nset -e\n", mode: 0o755},
+	{name: "usr/bin/tool", data: "#!/bin/sh\necho tool\n", mode: 0o755},
+	{name: "usr/bin/tool2", hardLink: "usr/bin/tool"},
+	{name: "usr/share/doc/built/copyright", data: "Public domain\n"},
+	{name: "usr/share/doc/built/README", link: "copyright"},
+	{name: "usr/lib/built/empty/", mode: 0o700},
+}
+
+// writeBuildTree creates files in dir, with every modification time apart from symlinks' set to mtime
+func writeBuildTree(t *testing.T, dir string, files []buildTreeFile, mtime time.Time) {
+	t.Helper()
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(name, "/")))
+	}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(path(f.name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		switch {
+		case strings.HasSuffix(f.name, "/"):
+			err = os.MkdirAll(path(f.name), 0o755)
+		case f.link != "":
+			err = os.Symlink(f.link, path(f.name))
+		case f.hardLink != "":
+			err = os.Link(path(f.hardLink), path(f.name))
+		default:
+			err = os.WriteFile(path(f.name), []byte(f.data), 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// set modes explicitly, so the umask doesn't matter, then times, parents last as adding
+	// to a directory changes its time
+	var dirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case info.IsDir():
+			dirs = append(dirs, path)
+			return os.Chmod(path, 0o755)
+		case info.Mode().IsRegular():
+			return os.Chmod(path, 0o644)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.mode != 0 {
+			if err := os.Chmod(path(f.name), f.mode); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && e.Type()&os.ModeSymlink == 0 {
+				if err := os.Chtimes(filepath.Join(dirs[i], e.Name()), mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := os.Chtimes(dirs[i], mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// buildPackage writes buildTree to a new directory and builds it
+func buildPackage(t *testing.T, opts BuildOptions) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	writeBuildTree(t, dir, buildTree, time.Unix(1700000000, 0))
+	var buf bytes.Buffer
+	if err := Build(dir, &buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBuildReproducible(t *testing.T) {
+	clamp := time.Unix(1600000000, 0)
+	for _, compression := range []string{"gzip", "xz", "zstd", "none"} {
+		t.Run(compression, func(t *testing.T) {
+			// the trees are written at different times, and newer than the clamp
+			first := t.TempDir()
+			writeBuildTree(t, first, buildTree, time.Unix(1700000000, 0))
+			second := t.TempDir()
+			writeBuildTree(t, second, buildTree, time.Unix(1800000000, 0))
+
+			var a, b bytes.Buffer
+			if err := Build(first, &a, BuildOptions{Compression: compression, ModTime: clamp}); err != nil {
+				t.Fatal(err)
+			}
+			if err := Build(second, &b, BuildOptions{Compression: compression, ModTime: clamp}); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(a.Bytes(), b.Bytes()) {
+				t.Error("building the same tree twice gave different packages")
+			}
+		})
+	}
+}
+
+func TestBuildDefaultModTime(t *testing.T) {
+	newest := time.Unix(1700000000, 0)
+	// symlinks are left out, as their own times can't be set
+	var files []buildTreeFile
+	for _, f := range buildTree {
+		if f.link == "" {
+			files = append(files, f)
+		}
+	}
+	dir := t.TempDir()
+	writeBuildTree(t, dir, files, newest.Add(-time.Hour))
+	if err := os.Chtimes(filepath.Join(dir, "usr/share/doc/built/copyright"), newest, newest); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Build(dir, &buf, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	if err := ar.ReadSignature(r); err != nil {
+		t.Fatal(err)
+	}
+	archive := ar.NewReader(r)
+	var names []string
+	for {
+		fi, err := archive.ReadFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, fi.Name)
+		if !fi.Mod.Equal(newest) || fi.Owner != 0 || fi.Group != 0 || fi.Mode != 0o100644 {
+			t.Errorf("member %s has time %s, owner %d:%d and mode %o", fi.Name, fi.Mod, fi.Owner, fi.Group, fi.Mode)
+		}
+	}
+	// xz is the default, like dpkg-deb
+	if want := []string{"debian-binary", "control.tar.xz", "data.tar.xz"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got members %q, want %q", names, want)
+	}
+}
+
+func TestBuildContents(t *testing.T) {
+	clamp := time.Unix(1600000000, 0)
+	pkg, err := Open(bytes.NewReader(buildPackage(t, BuildOptions{Compression: "gzip", ModTime: clamp})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Control != buildControl || pkg.ControlCompression != "gzip" {
+		t.Errorf("control is %q, compressed with %s", pkg.Control, pkg.ControlCompression)
+	}
+	if m := pkg.ControlMember("postinst"); m == nil || m.Mode.Perm() != 0o755 {
+		t.Errorf("postinst is %+v", m)
+	}
+
+	data, err := pkg.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+	var entries []string
+	for {
+		hdr, err := data.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "root" || hdr.Gname != "root" {
+			t.Errorf("%s is owned by %s:%s (%d:%d)", hdr.Name, hdr.Uname, hdr.Gname, hdr.Uid, hdr.Gid)
+		}
+		if !hdr.ModTime.Equal(clamp) {
+			t.Errorf("%s has time %s, want %s", hdr.Name, hdr.ModTime, clamp)
+		}
+		entry := hdr.Name + " " + hdr.FileInfo().Mode().String()
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			entry += " -> " + hdr.Linkname
+		case tar.TypeLink:
+			entry += " => " + hdr.Linkname
+		}
+		entries = append(entries, entry)
+	}
+	// sorted, with parents first, DEBIAN left out and the second name of the hard link made a link
+	want := []string{
+		"./ drwxr-xr-x",
+		"./usr/ drwxr-xr-x",
+		"./usr/bin/ drwxr-xr-x",
+		"./usr/bin/tool -rwxr-xr-x",
+		"./usr/bin/tool2 -rwxr-xr-x => ./usr/bin/tool",
+		"./usr/lib/ drwxr-xr-x",
+		"./usr/lib/built/ drwxr-xr-x",
+		"./usr/lib/built/empty/ drwx------",
+		"./usr/share/ drwxr-xr-x",
+		"./usr/share/doc/ drwxr-xr-x",
+		"./usr/share/doc/built/ drwxr-xr-x",
+		"./usr/share/doc/built/README Lrwxrwxrwx -> copyright",
+		"./usr/share/doc/built/copyright -rw-r--r--",
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got entries\n%s\nwant\n%s", strings.Join(entries, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		files   []buildTreeFile
+		opts    BuildOptions
+		message string
+	}{
+		{"no control directory", []buildTreeFile{{name: "usr/bin/tool", data: "x"}}, BuildOptions{}, "control directory"},
+		{"no control file", []buildTreeFile{{name: "DEBIAN/postinst", data: "#!/bin/sh\n", mode: 0o755}}, BuildOptions{}, "no control file"},
+		{"no Package", []buildTreeFile{{name: "DEBIAN/control", data: "Version: 1.0\nArchitecture: all\n"}}, BuildOptions{}, "no Package field"},
+		{"no Architecture", []buildTreeFile{{name: "DEBIAN/control", data: "Package: a\nVersion: 1.0\n"}}, BuildOptions{}, "no Architecture field"},
+		{"bad Version", []buildTreeFile{{name: "DEBIAN/control", data: "Package: a\nVersion: 1.0_1\nArchitecture: all\n"}}, BuildOptions{}, "invalid Version"},
+		{"unparseable control", []buildTreeFile{{name: "DEBIAN/control", data: "Package a\n"}}, BuildOptions{}, "failed to parse"},
+		{
+			"script not executable",
+			[]buildTreeFile{{name: "DEBIAN/control", data: buildControl}, {name: "DEBIAN/postinst", data: "#!/bin/sh\n", mode: 0o644}},
+			BuildOptions{}, "must be readable and executable",
+		},
+		{
+			"control subdirectory",
+			[]buildTreeFile{{name: "DEBIAN/control", data: buildControl}, {name: "DEBIAN/extra/", mode: 0o755}},
+			BuildOptions{}, "not a regular file",
+		},
+		{"unknown compression", []buildTreeFile{{name: "DEBIAN/control", data: buildControl}}, BuildOptions{Compression: "lz4"}, "lz4"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeBuildTree(t, dir, test.files, time.Unix(1700000000, 0))
+			err := Build(dir, io.Discard, test.opts)
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected an error containing %q, got %v", test.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+
+	"github.com/porty/deb-info/ar"
+)
+
+// decompressor handles one of the compression formats dpkg supports for control.tar* and data.tar*
+type decompressor struct {
+	name string
+	ext  string
+	// magic reports whether the start of a member looks like this format
+	magic func(header []byte) bool
+	open  func(r io.Reader) (io.ReadCloser, error)
+}
+
+// decompressors is in order of magic byte detection, most specific first
+var decompressors = []decompressor{
+	{
+		name:  "gzip",
+		ext:   ".gz",
+		magic: hasMagic("\x1f\x8b"),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name:  "xz",
+		ext:   ".xz",
+		magic: hasMagic("\xfd7zXZ\x00"),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			xzr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(xzr), nil
+		},
+	},
+	{
+		name:  "zstd",
+		ext:   ".zst",
+		magic: hasMagic("\x28\xb5\x2f\xfd"),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+	},
+	{
+		name:  "bzip2",
+		ext:   ".bz2",
+		magic: hasMagic("BZh"),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		name: "none",
+		ext:  "",
+		magic: func(header []byte) bool {
+			// tar has no magic at the start, but ustar headers have one at 257
+			return len(header) >= 262 && string(header[257:262]) == "ustar"
+		},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	},
+	{
+		name: "lzma",
+		ext:  ".lzma",
+		magic: func(header []byte) bool {
+			// lzma-alone has no magic, but the properties byte is nearly always 0x5d
+			// followed by a little endian dictionary size
+			return len(header) >= 13 && header[0] == 0x5d && header[1] == 0
+		},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			lr, err := lzma.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(lr), nil
+		},
+	},
+}
+
+func hasMagic(magic string) func([]byte) bool {
+	return func(header []byte) bool {
+		return bytes.HasPrefix(header, []byte(magic))
+	}
+}
+
+// detectDecompressor picks the decompressor for a member called base+".tar"+ext,
+// preferring the format its magic bytes say over its name
+func detectDecompressor(name string, base string, header []byte) (*decompressor, error) {
+	if !strings.HasPrefix(name, base+".tar") {
+		return nil, fmt.Errorf("unknown/unhandled %s file extension: %s", base, name)
+	}
+
+	for i := range decompressors {
+		if decompressors[i].magic(header) {
+			return &decompressors[i], nil
+		}
+	}
+
+	ext := strings.TrimPrefix(name, base+".tar")
+	for i := range decompressors {
+		if decompressors[i].ext == ext {
+			return &decompressors[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown/unhandled %s file extension: %s", base, name)
+}
+
+// compressor writes one of the formats dpkg supports for control.tar* and data.tar*
+type compressor struct {
+	name string
+	ext  string
+	// minLevel and maxLevel are the compression levels accepted, both 0 if there are none
+	minLevel int
+	maxLevel int
+	// create starts a compressed stream, with level 0 meaning the format's default
+	create func(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// xzDictCaps are the dictionary sizes of the xz presets, by level
+var xzDictCaps = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+var compressors = []compressor{
+	{
+		name:     "gzip",
+		ext:      ".gz",
+		minLevel: 1,
+		maxLevel: 9,
+		create: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			// the header has no name or time, so output is reproducible
+			return gzip.NewWriterLevel(w, level)
+		},
+	},
+	{
+		name:     "xz",
+		ext:      ".xz",
+		minLevel: 1,
+		maxLevel: 9,
+		create: func(w io.Writer, level int) (io.WriteCloser, error) {
+			// the xz package only has the one match finder, so levels just choose the dictionary size
+			var config xz.WriterConfig
+			if level != 0 {
+				config.DictCap = xzDictCaps[level]
+			}
+			return config.NewWriter(w)
+		},
+	},
+	{
+		name:     "zstd",
+		ext:      ".zst",
+		minLevel: 1,
+		maxLevel: 22,
+		create: func(w io.Writer, level int) (io.WriteCloser, error) {
+			opts := []zstd.EOption{
+				// keeps the output the same whatever the number of CPUs
+				zstd.WithEncoderConcurrency(1),
+			}
+			if level != 0 {
+				opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+			}
+			return zstd.NewWriter(w, opts...)
+		},
+	},
+	{
+		name: "none",
+		ext:  "",
+		create: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return nopWriteCloser{w}, nil
+		},
+	},
+}
+
+// checkLevel returns an error if level isn't 0 or one the compressor accepts
+func (c *compressor) checkLevel(level int) error {
+	if level == 0 {
+		return nil
+	}
+	if c.maxLevel == 0 {
+		return fmt.Errorf("%s compression has no levels", c.name)
+	}
+	if level < c.minLevel || level > c.maxLevel {
+		return fmt.Errorf("%s compression level must be from %d to %d", c.name, c.minLevel, c.maxLevel)
+	}
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// findCompressor returns the compressor called name, also accepting its file extension, e.g. "gz"
+func findCompressor(name string) (*compressor, error) {
+	for i := range compressors {
+		c := &compressors[i]
+		if name == c.name || (c.ext != "" && name == strings.TrimPrefix(c.ext, ".")) {
+			return c, nil
+		}
+	}
+	var names []string
+	for _, c := range compressors {
+		names = append(names, c.name)
+	}
+	return nil, fmt.Errorf("unknown compression %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// openTar returns the uncompressed tar stream of a control.tar* or data.tar* member,
+// and the name of the compression used
+func openTar(fi *ar.FileInfo, base string) (io.ReadCloser, string, error) {
+	br := bufio.NewReaderSize(fi.Reader, 512)
+	// short members just give a short header
+	header, _ := br.Peek(512)
+
+	d, err := detectDecompressor(fi.Name, base, header)
+	if err != nil {
+		return nil, "", err
+	}
+	r, err := d.open(br)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to initialize %s reader: %w", d.name, err)
+	}
+	return r, d.name, nil
+}
@@ -0,0 +1,10 @@
+package deb
+
+import (
+	"github.com/porty/deb-info/deb822"
+)
+
+// ParseControl parses a binary package control file, which must hold a single paragraph
+func ParseControl(control string) (*deb822.Paragraph, error) {
+	return deb822.ParseParagraph(control)
+}
@@ -0,0 +1,40 @@
+package deb
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+)
+
+// DataReader iterates over the entries of the data archive, like tar.Reader
+type DataReader struct {
+	// Compression is the name of the data archive compression, e.g. "gzip"
+	Compression string
+	// CompressedSize is the size of the data archive member in the package
+	CompressedSize int64
+
+	tr     *tar.Reader
+	closer io.Closer
+}
+
+// Next advances to the next entry, returning io.EOF at the end of the archive
+func (d *DataReader) Next() (*tar.Header, error) {
+	hdr, err := d.tr.Next()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file from data archive: %w", err)
+	}
+	return hdr, nil
+}
+
+// Read reads from the current entry
+func (d *DataReader) Read(b []byte) (int, error) {
+	return d.tr.Read(b)
+}
+
+// Close releases the decompressor, it does not close the underlying package reader
+func (d *DataReader) Close() error {
+	return d.closer.Close()
+}
@@ -0,0 +1,291 @@
+// Package deb reads Debian binary packages (.deb files)
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/porty/deb-info/ar"
+	"github.com/porty/deb-info/deb822"
+)
+
+// the control archive is read into memory, so refuse anything unreasonable
+const (
+	maxControlArchiveSize = 100 * 1024
+	// and the same once it's decompressed, as that can be far bigger. md5sums is the
+	// only member that gets large, at a few megabytes for packages of many files.
+	maxControlMemberSize = 16 << 20
+	maxControlSize       = 32 << 20
+)
+
+// controlBudget limits how much of a decompressed control archive is read
+type controlBudget struct {
+	total int64
+}
+
+// add counts the entry hdr, failing with ErrExtractLimit if it is too big
+func (b *controlBudget) add(hdr *tar.Header) error {
+	if hdr.Size > maxControlMemberSize {
+		return fmt.Errorf("%w: control archive member %s is %d bytes, more than %d", ErrExtractLimit, hdr.Name, hdr.Size, maxControlMemberSize)
+	}
+	// each entry has a 512 byte header, so lots of empty entries count too
+	b.total += 512 + hdr.Size
+	if b.total > maxControlSize {
+		return fmt.Errorf("%w: control archive is more than %d bytes decompressed", ErrExtractLimit, maxControlSize)
+	}
+	return nil
+}
+
+// read reads the contents of the entry just counted by add
+func (b *controlBudget) read(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := io.Copy(&buf, io.LimitReader(r, maxControlMemberSize))
+	return buf.Bytes(), err
+}
+
+// Package is a Debian package opened with Open or OpenReaderAt. The debian-binary and
+// control members are read up front, the data archive is streamed with Data.
+type Package struct {
+	// FormatVersion is the contents of debian-binary, without the trailing newline
+	FormatVersion string
+	// Control is the contents of the control file
+	Control string
+	// ControlMembers are all the files in the control archive, in archive order
+	ControlMembers []*ControlMember
+	// ControlCompression is the name of the control archive compression, e.g. "gzip"
+	ControlCompression string
+
+	// next returns the next ar member, from an ar.Reader or an ar.Index
+	next func() (*ar.FileInfo, error)
+	// index and dataMember are set by OpenReaderAt, so Data can seek straight to the data archive
+	index      *ar.Index
+	dataMember *ar.Member
+	dataOpened bool
+}
+
+// MaintainerScripts are the control members run by dpkg during installation and removal
+var MaintainerScripts = []string{"preinst", "postinst", "prerm", "postrm", "config"}
+
+// ControlMember is a file from the control archive
+type ControlMember struct {
+	// Name is the path within the control archive without any "./" prefix, e.g. "postinst"
+	Name string
+	Mode os.FileMode
+	Size int64
+	Data []byte
+}
+
+// IsMaintainerScript reports whether the member is one of MaintainerScripts
+func (m *ControlMember) IsMaintainerScript() bool {
+	for _, name := range MaintainerScripts {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Open reads the signature, debian-binary and control archive of the package in r,
+// leaving r positioned at the start of the data archive
+func Open(r io.Reader) (*Package, error) {
+	if err := ar.ReadSignature(r); err != nil {
+		return nil, fmt.Errorf("failed to read Debian package header: %w", err)
+	}
+
+	p := &Package{
+		next: ar.NewReader(r).ReadFile,
+	}
+	if err := p.readHeader(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OpenReaderAt reads the debian-binary and control archive of the package in r, which is
+// size bytes long. The ar member headers are indexed first, so Data seeks straight to the
+// data archive and can be called more than once.
+func OpenReaderAt(r io.ReaderAt, size int64) (*Package, error) {
+	index, err := ar.OpenReaderAt(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Debian package: %w", err)
+	}
+
+	members := index.Members
+	p := &Package{
+		index: index,
+		next: func() (*ar.FileInfo, error) {
+			if len(members) == 0 {
+				return nil, io.EOF
+			}
+			m := members[0]
+			members = members[1:]
+			fi := m.FileInfo
+			fi.Reader = index.OpenMember(m)
+			return &fi, nil
+		},
+	}
+	if err := p.readHeader(); err != nil {
+		return nil, err
+	}
+	if len(members) > 0 {
+		p.dataMember = members[0]
+	}
+	return p, nil
+}
+
+// readHeader reads the debian-binary and control archive members
+func (p *Package) readHeader() error {
+	fi, err := p.next()
+	if err != nil {
+		return fmt.Errorf("failed to read debian-binary: %w", err)
+	}
+	version, err := readDebianBinary(fi)
+	if err != nil {
+		return fmt.Errorf("failed to read debian-binary: %w", err)
+	}
+	p.FormatVersion = version
+
+	if err := p.readControl(); err != nil {
+		return fmt.Errorf("failed to read control file: %w", err)
+	}
+	return nil
+}
+
+// ControlFields parses the control file
+func (p *Package) ControlFields() (*deb822.Paragraph, error) {
+	return ParseControl(p.Control)
+}
+
+func readDebianBinary(fi *ar.FileInfo) (string, error) {
+	const debianBinary = "debian-binary"
+	if fi.Name != debianBinary {
+		return "", fmt.Errorf("expected file %q, got %q", debianBinary, fi.Name)
+	}
+	if fi.Size != 4 {
+		return "", fmt.Errorf("expected size %d, got %d", 4, fi.Size)
+	}
+	var buf bytes.Buffer
+
+	read, err := io.Copy(&buf, fi.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read: %w", err)
+	}
+	if read != 4 {
+		return "", fmt.Errorf("failed to read: expected %d bytes, read %d bytes", 4, read)
+	}
+	if buf.String() != "2.0\n" {
+		return "", errors.New("invalid debian-binary value")
+	}
+	return "2.0", nil
+}
+
+func (p *Package) readControl() error {
+	fi, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size > maxControlArchiveSize {
+		return fmt.Errorf("control archive too large at %d bytes", fi.Size)
+	}
+
+	r, compression, err := openTar(fi, "control")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	p.ControlCompression = compression
+
+	foundControl := false
+	var budget controlBudget
+	tr := tar.NewReader(r)
+	for {
+		tarFile, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file from control archive: %w", err)
+		}
+		if err := budget.add(tarFile); err != nil {
+			return err
+		}
+
+		if tarFile.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		data, err := budget.read(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", tarFile.Name, err)
+		}
+		member := &ControlMember{
+			Name: path.Clean(strings.TrimPrefix(tarFile.Name, "./")),
+			Mode: tarFile.FileInfo().Mode(),
+			Size: tarFile.Size,
+			Data: data,
+		}
+		p.ControlMembers = append(p.ControlMembers, member)
+
+		if member.Name == "control" {
+			p.Control = string(data)
+			foundControl = true
+		}
+	}
+
+	if !foundControl {
+		return errors.New("failed to find control file in control archive")
+	}
+	return nil
+}
+
+// ControlMember returns the named control archive member, or nil if there isn't one
+func (p *Package) ControlMember(name string) *ControlMember {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	for _, m := range p.ControlMembers {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Data opens the data archive. For a package opened with Open it can only be called once,
+// as the package is read sequentially, but with OpenReaderAt each call starts again.
+func (p *Package) Data() (*DataReader, error) {
+	var fi *ar.FileInfo
+	switch {
+	case p.dataMember != nil:
+		member := *p.dataMember
+		fi = &member.FileInfo
+		fi.Reader = p.index.OpenMember(p.dataMember)
+	case p.index != nil:
+		return nil, errors.New("package has no data archive")
+	case p.dataOpened:
+		return nil, errors.New("data archive has already been read")
+	default:
+		p.dataOpened = true
+		var err error
+		fi, err = p.next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	r, compression, err := openTar(fi, "data")
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataReader{
+		Compression:    compression,
+		CompressedSize: fi.Size,
+		tr:             tar.NewReader(r),
+		closer:         r,
+	}, nil
+}
@@ -0,0 +1,158 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/porty/deb-info/ar"
+)
+
+func TestOpenControlBomb(t *testing.T) {
+	zeros := strings.Repeat("\x00", 12<<20)
+	for _, test := range []struct {
+		name    string
+		entries []testEntry
+	}{
+		{"big member", []testEntry{tarFile("./control", testControl), tarFile("./zeros", zeros+zeros)}},
+		{"big archive", []testEntry{tarFile("./control", testControl), tarFile("./a", zeros), tarFile("./b", zeros), tarFile("./c", zeros)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			control := makeTar(t, test.entries)
+			if len(control) > maxControlArchiveSize {
+				t.Fatalf("compressed control archive is %d bytes, too big to test the decompressed limits", len(control))
+			}
+			_, err := Open(bytes.NewReader(makePackage(t, control, makeTar(t, nil))))
+			if !errors.Is(err, ErrExtractLimit) {
+				t.Fatalf("expected ErrExtractLimit, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenControlMembers(t *testing.T) {
+	control := makeTar(t, []testEntry{
+		tarDir("./"),
+		tarFile("./control", testControl),
+		{name: "./postinst", data: "#!/bin/sh\n", mode: 0o755},
+		tarFile("./md5sums", strings.Repeat("d41d8cd98f00b204e9800998ecf8427e  usr/share/doc/x\n", 1000)),
+	})
+	pkg, err := Open(bytes.NewReader(makePackage(t, control, makeTar(t, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Control != testControl {
+		t.Errorf("control is %q", pkg.Control)
+	}
+	var names []string
+	for _, m := range pkg.ControlMembers {
+		names = append(names, m.Name)
+	}
+	if strings.Join(names, " ") != "control postinst md5sums" {
+		t.Errorf("control members are %v", names)
+	}
+	if m := pkg.ControlMember("./postinst"); m == nil || !m.IsMaintainerScript() || m.Mode.Perm() != 0o755 {
+		t.Errorf("postinst is %+v", m)
+	}
+}
+
+// dataNames lists the entries of the package's data archive
+func dataNames(t *testing.T, pkg *Package) []string {
+	t.Helper()
+	data, err := pkg.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+	var names []string
+	for {
+		hdr, err := data.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	control := makeTar(t, []testEntry{tarFile("./control", testControl)})
+	file := makePackage(t, control, makeTar(t, []testEntry{tarDir("./"), tarFile("./a", "a"), tarFile("./b", "b")}))
+
+	pkg, err := OpenReaderAt(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.FormatVersion != "2.0" || pkg.Control != testControl || pkg.ControlCompression != "gzip" {
+		t.Errorf("unexpected package %+v", pkg)
+	}
+	// unlike Open, the data archive can be read more than once
+	for i := 0; i < 2; i++ {
+		if names := strings.Join(dataNames(t, pkg), " "); names != "./ ./a ./b" {
+			t.Errorf("pass %d read data entries %s", i, names)
+		}
+	}
+
+	seq, err := Open(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataNames(t, seq)
+	if _, err := seq.Data(); err == nil {
+		t.Error("Open allowed the data archive to be read twice")
+	}
+}
+
+func TestOpenReaderAtErrors(t *testing.T) {
+	control := makeTar(t, []testEntry{tarFile("./control", testControl)})
+	valid := makePackage(t, control, makeTar(t, nil))
+
+	var noData bytes.Buffer
+	w := ar.NewWriter(&noData)
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+	} {
+		if err := w.WriteFile(&ar.FileInfo{Name: m.name, Mode: 0o100644, Size: int64(len(m.data)), Reader: bytes.NewReader(m.data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		// openErr is whether OpenReaderAt fails, otherwise Data does
+		openErr bool
+	}{
+		{"not an archive", []byte("hello, world\n"), true},
+		{"truncated data archive", valid[:len(valid)-10], true},
+		{"bad debian-binary", bytes.Replace(valid, []byte("2.0\n"), []byte("3.0\n"), 1), true},
+		{"no data archive", noData.Bytes(), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pkg, err := OpenReaderAt(bytes.NewReader(test.data), int64(len(test.data)))
+			if test.openErr {
+				if err == nil {
+					t.Fatal("OpenReaderAt succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := pkg.Data(); err == nil {
+				t.Error("Data succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package deb
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/porty/deb-info/deb822"
+)
+
+// Kinds of change, as used in PackageDiff
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// PackageDiff is the difference between two packages, see Diff
+type PackageDiff struct {
+	Fields    []*FieldChange    `json:"fields,omitempty"`
+	Relations []*RelationChange `json:"relations,omitempty"`
+	// Members are the changed control archive members other than control and md5sums,
+	// which are covered by Fields and Files
+	Members []*MemberChange `json:"control_members,omitempty"`
+	Files   []*FileChange   `json:"files,omitempty"`
+}
+
+// Empty reports whether the packages were the same, ignoring modification times
+func (d *PackageDiff) Empty() bool {
+	return len(d.Fields) == 0 && len(d.Relations) == 0 && len(d.Members) == 0 && len(d.Files) == 0
+}
+
+// FieldChange is a control field that was added, removed or changed
+type FieldChange struct {
+	Field  string `json:"field"`
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// RelationChange is the change to a relationship field such as Depends. Relations are matched
+// by the package names in them, so a new version constraint is a change rather than a removal
+// and an addition.
+type RelationChange struct {
+	Field   string         `json:"field"`
+	Added   []string       `json:"added,omitempty"`
+	Removed []string       `json:"removed,omitempty"`
+	Changed []*ValueChange `json:"changed,omitempty"`
+}
+
+// ValueChange is an old and new value
+type ValueChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// MemberChange is a control archive member, such as a maintainer script, that was added, removed or changed
+type MemberChange struct {
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	// Diff is a unified diff from the old to the new contents, empty if only the mode changed
+	Diff string `json:"diff,omitempty"`
+	// Mode is set if a changed member's mode changed, e.g. "-rwxr-xr-x -> -rw-r--r--"
+	Mode string `json:"mode,omitempty"`
+}
+
+// FileChange is a data archive entry that was added, removed or changed
+type FileChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	// Details say what changed about changed entries, e.g. "size 100 -> 120"
+	Details []string  `json:"details,omitempty"`
+	Old     *FileInfo `json:"old,omitempty"`
+	New     *FileInfo `json:"new,omitempty"`
+}
+
+// Diff compares two packages: their control fields, other control members and data archive
+// entries. Modification times are ignored. It reads both data archives, so neither package
+// can be used with Data afterwards.
+func Diff(oldPkg *Package, newPkg *Package) (*PackageDiff, error) {
+	oldControl, err := oldPkg.ControlFields()
+	if err != nil {
+		return nil, fmt.Errorf("old package: %w", err)
+	}
+	newControl, err := newPkg.ControlFields()
+	if err != nil {
+		return nil, fmt.Errorf("new package: %w", err)
+	}
+
+	d := &PackageDiff{}
+	d.Fields, d.Relations = diffControl(oldControl, newControl)
+	d.Members = diffMembers(oldPkg, newPkg)
+
+	oldFiles, err := readFileInfos(oldPkg)
+	if err != nil {
+		return nil, fmt.Errorf("old package: %w", err)
+	}
+	newFiles, err := readFileInfos(newPkg)
+	if err != nil {
+		return nil, fmt.Errorf("new package: %w", err)
+	}
+	d.Files = diffFiles(oldFiles, newFiles)
+
+	return d, nil
+}
+
+// diffControl compares control fields, with relationship fields that parse compared relation by relation
+func diffControl(oldControl *deb822.Paragraph, newControl *deb822.Paragraph) ([]*FieldChange, []*RelationChange) {
+	var fields []*FieldChange
+	var relations []*RelationChange
+
+	structured := map[string]bool{}
+	for _, name := range RelationFields {
+		oldValue, _ := oldControl.Get(name)
+		newValue, _ := newControl.Get(name)
+		if oldValue == newValue {
+			structured[name] = true
+			continue
+		}
+		oldRelations, oldErr := ParseRelations(oldValue)
+		newRelations, newErr := ParseRelations(newValue)
+		if oldErr != nil || newErr != nil {
+			// shown as a plain field change instead
+			continue
+		}
+		structured[name] = true
+		if change := diffRelations(name, oldRelations, newRelations); change != nil {
+			relations = append(relations, change)
+		}
+	}
+
+	skip := func(name string) bool {
+		for field := range structured {
+			if strings.EqualFold(field, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range oldControl.Fields {
+		if skip(f.Name) {
+			continue
+		}
+		newValue, ok := newControl.Get(f.Name)
+		switch {
+		case !ok:
+			fields = append(fields, &FieldChange{Field: f.Name, Change: ChangeRemoved, Old: f.Value})
+		case newValue != f.Value:
+			fields = append(fields, &FieldChange{Field: f.Name, Change: ChangeChanged, Old: f.Value, New: newValue})
+		}
+	}
+	for _, f := range newControl.Fields {
+		if skip(f.Name) {
+			continue
+		}
+		if oldControl.Field(f.Name) == nil {
+			fields = append(fields, &FieldChange{Field: f.Name, Change: ChangeAdded, New: f.Value})
+		}
+	}
+
+	return fields, relations
+}
+
+// relationKey identifies alternatives by the packages in them, ignoring versions and restrictions
+func relationKey(a Alternatives) string {
+	var names []string
+	for _, r := range a {
+		name := r.Name
+		if r.ArchQualifier != "" {
+			name += ":" + r.ArchQualifier
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " | ")
+}
+
+func diffRelations(field string, oldRelations Relations, newRelations Relations) *RelationChange {
+	change := &RelationChange{Field: field}
+
+	// the same packages may appear more than once, e.g. with different architecture restrictions,
+	// so they are matched up in order
+	newByKey := map[string][]Alternatives{}
+	for _, a := range newRelations {
+		key := relationKey(a)
+		newByKey[key] = append(newByKey[key], a)
+	}
+	matched := map[string]int{}
+	for _, a := range oldRelations {
+		key := relationKey(a)
+		candidates := newByKey[key]
+		if matched[key] >= len(candidates) {
+			change.Removed = append(change.Removed, a.String())
+			continue
+		}
+		b := candidates[matched[key]]
+		matched[key]++
+		if a.String() != b.String() {
+			change.Changed = append(change.Changed, &ValueChange{Old: a.String(), New: b.String()})
+		}
+	}
+	seen := map[string]int{}
+	for _, a := range newRelations {
+		key := relationKey(a)
+		seen[key]++
+		if seen[key] > matched[key] {
+			change.Added = append(change.Added, a.String())
+		}
+	}
+
+	if len(change.Added) == 0 && len(change.Removed) == 0 && len(change.Changed) == 0 {
+		return nil
+	}
+	return change
+}
+
+// diffMembers compares the control archive members, other than control and md5sums
+func diffMembers(oldPkg *Package, newPkg *Package) []*MemberChange {
+	skip := func(name string) bool {
+		return name == "control" || name == "md5sums"
+	}
+
+	var changes []*MemberChange
+	for _, m := range oldPkg.ControlMembers {
+		if skip(m.Name) {
+			continue
+		}
+		other := newPkg.ControlMember(m.Name)
+		switch {
+		case other == nil:
+			changes = append(changes, &MemberChange{
+				Name:   m.Name,
+				Change: ChangeRemoved,
+				Diff:   unifiedDiff("a/"+m.Name, "/dev/null", string(m.Data), ""),
+			})
+		case string(other.Data) != string(m.Data) || other.Mode != m.Mode:
+			change := &MemberChange{
+				Name:   m.Name,
+				Change: ChangeChanged,
+				Diff:   unifiedDiff("a/"+m.Name, "b/"+m.Name, string(m.Data), string(other.Data)),
+			}
+			if other.Mode != m.Mode {
+				change.Mode = fmt.Sprintf("%s -> %s", m.Mode, other.Mode)
+			}
+			changes = append(changes, change)
+		}
+	}
+	for _, m := range newPkg.ControlMembers {
+		if skip(m.Name) || oldPkg.ControlMember(m.Name) != nil {
+			continue
+		}
+		changes = append(changes, &MemberChange{
+			Name:   m.Name,
+			Change: ChangeAdded,
+			Diff:   unifiedDiff("/dev/null", "b/"+m.Name, "", string(m.Data)),
+		})
+	}
+	return changes
+}
+
+// readFileInfos describes every data archive entry, with SHA-256 hashes, keyed by normalized path
+func readFileInfos(p *Package) (map[string]*FileInfo, error) {
+	data, err := p.Data()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	result := map[string]*FileInfo{}
+	opts := DescribeOptions{Hashes: []string{"sha256"}}
+	for {
+		hdr, err := data.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fi, err := Describe(hdr, data, opts)
+		if err != nil {
+			return nil, err
+		}
+		result[NormalizePath(hdr.Name)] = fi
+	}
+	return result, nil
+}
+
+// diffFiles compares data archive entries, sorted by path
+func diffFiles(oldFiles map[string]*FileInfo, newFiles map[string]*FileInfo) []*FileChange {
+	var changes []*FileChange
+	for name, a := range oldFiles {
+		b, ok := newFiles[name]
+		if !ok {
+			changes = append(changes, &FileChange{Path: name, Change: ChangeRemoved, Old: a})
+			continue
+		}
+		if details := fileDetails(a, b); len(details) > 0 {
+			changes = append(changes, &FileChange{Path: name, Change: ChangeChanged, Details: details, Old: a, New: b})
+		}
+	}
+	for name, b := range newFiles {
+		if _, ok := oldFiles[name]; !ok {
+			changes = append(changes, &FileChange{Path: name, Change: ChangeAdded, New: b})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// fileDetails describes what changed between two versions of an entry
+func fileDetails(a *FileInfo, b *FileInfo) []string {
+	var details []string
+	changed := func(what string, oldValue string, newValue string) {
+		if oldValue != newValue {
+			details = append(details, fmt.Sprintf("%s %s -> %s", what, oldValue, newValue))
+		}
+	}
+
+	changed("type", a.Type, b.Type)
+	changed("mode", a.Mode, b.Mode)
+	changed("owner", a.Owner(), b.Owner())
+	if a.Type == TypeFile && b.Type == TypeFile {
+		changed("size", fmt.Sprint(a.Size), fmt.Sprint(b.Size))
+		if a.SHA256 != b.SHA256 {
+			details = append(details, "sha256 changed")
+		}
+	}
+	changed("link target", a.LinkTarget, b.LinkTarget)
+	if a.Device != nil && b.Device != nil {
+		changed("device", fmt.Sprintf("%d,%d", a.Device.Major, a.Device.Minor), fmt.Sprintf("%d,%d", b.Device.Major, b.Device.Minor))
+	}
+	return details
+}
@@ -0,0 +1,234 @@
+package deb
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/porty/deb-info/deb822"
+)
+
+func TestDiffControl(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		old, new  string
+		fields    []*FieldChange
+		relations []*RelationChange
+	}{
+		{"same", "Package: a\nVersion: 1\n", "Package: a\nVersion: 1\n", nil, nil},
+		{
+			"changed, added and removed fields",
+			"Package: a\nVersion: 1\nSection: misc\n",
+			"Package: a\nVersion: 2\nHomepage: https://example.com\n",
+			[]*FieldChange{
+				{Field: "Version", Change: ChangeChanged, Old: "1", New: "2"},
+				{Field: "Section", Change: ChangeRemoved, Old: "misc"},
+				{Field: "Homepage", Change: ChangeAdded, New: "https://example.com"},
+			},
+			nil,
+		},
+		{
+			"field names are case-insensitive",
+			"Package: a\nsection: misc\n",
+			"Package: a\nSection: misc\n",
+			nil, nil,
+		},
+		{
+			"relations matched by package",
+			"Package: a\nDepends: libc6 (>= 2.34), foo, bar | baz\n",
+			"Package: a\nDepends: libc6 (>= 2.36), bar | baz, qux\n",
+			nil,
+			[]*RelationChange{{
+				Field:   "Depends",
+				Added:   []string{"qux"},
+				Removed: []string{"foo"},
+				Changed: []*ValueChange{{Old: "libc6 (>= 2.34)", New: "libc6 (>= 2.36)"}},
+			}},
+		},
+		{
+			"relation field added",
+			"Package: a\n",
+			"Package: a\nBreaks: b (<< 2)\n",
+			nil,
+			[]*RelationChange{{Field: "Breaks", Added: []string{"b (<< 2)"}}},
+		},
+		{
+			"only formatting changed",
+			"Package: a\nDepends: b(>=1),c\n",
+			"Package: a\nDepends: b (>= 1), c\n",
+			nil, nil,
+		},
+		{
+			"unparseable relations are a field change",
+			"Package: a\nDepends: b (>= 1\n",
+			"Package: a\nDepends: b (>= 2)\n",
+			[]*FieldChange{{Field: "Depends", Change: ChangeChanged, Old: "b (>= 1", New: "b (>= 2)"}},
+			nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			oldControl, err := deb822.ParseParagraph(test.old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newControl, err := deb822.ParseParagraph(test.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields, relations := diffControl(oldControl, newControl)
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("got fields %s, want %s", jsonString(t, fields), jsonString(t, test.fields))
+			}
+			if !reflect.DeepEqual(relations, test.relations) {
+				t.Errorf("got relations %s, want %s", jsonString(t, relations), jsonString(t, test.relations))
+			}
+		})
+	}
+}
+
+func TestFileDetails(t *testing.T) {
+	file := FileInfo{Type: TypeFile, Mode: "-rw-r--r--", Size: 3, SHA256: "aaa"}
+	for _, test := range []struct {
+		name string
+		a, b FileInfo
+		want []string
+	}{
+		{"same", file, file, nil},
+		{"mtime ignored", file, FileInfo{Type: TypeFile, Mode: "-rw-r--r--", Size: 3, SHA256: "aaa", ModTime: file.ModTime.Add(1e9)}, nil},
+		{"contents", file, FileInfo{Type: TypeFile, Mode: "-rw-r--r--", Size: 3, SHA256: "bbb"}, []string{"sha256 changed"}},
+		{"size", file, FileInfo{Type: TypeFile, Mode: "-rw-r--r--", Size: 4, SHA256: "bbb"}, []string{"size 3 -> 4", "sha256 changed"}},
+		{"mode", file, FileInfo{Type: TypeFile, Mode: "-rwxr-xr-x", Size: 3, SHA256: "aaa"}, []string{"mode -rw-r--r-- -> -rwxr-xr-x"}},
+		{"owner", file, FileInfo{Type: TypeFile, Mode: "-rw-r--r--", Size: 3, SHA256: "aaa", UID: 1000, GID: 1000}, []string{"owner 0:0 -> 1000:1000"}},
+		{
+			"type",
+			file, FileInfo{Type: TypeSymlink, Mode: "Lrwxrwxrwx", LinkTarget: "b"},
+			[]string{"type file -> symlink", "mode -rw-r--r-- -> Lrwxrwxrwx", "link target  -> b"},
+		},
+		{
+			"link target",
+			FileInfo{Type: TypeSymlink, LinkTarget: "a"}, FileInfo{Type: TypeSymlink, LinkTarget: "b"},
+			[]string{"link target a -> b"},
+		},
+		{
+			"device",
+			FileInfo{Type: TypeChar, Device: &Device{1, 3}}, FileInfo{Type: TypeChar, Device: &Device{1, 5}},
+			[]string{"device 1,3 -> 1,5"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := fileDetails(&test.a, &test.b); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	openPackage := func(control string, members []testEntry, data []testEntry) *Package {
+		t.Helper()
+		entries := append([]testEntry{tarFile("./control", control)}, members...)
+		pkg, err := Open(bytes.NewReader(makePackage(t, makeTar(t, entries), makeTar(t, data))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+	oldPkg := openPackage(
+		"Package: test\nVersion: 1.0\nArchitecture: all\nDepends: a (>= 1)\n",
+		[]testEntry{
+			{name: "./postinst", data: "#!/bin/sh\nset -e\necho old\n", mode: 0o755},
+			{name: "./prerm", data: "#!/bin/sh\n", mode: 0o755},
+			{name: "./config", data: "#!/bin/sh\n", mode: 0o755},
+			tarFile("./md5sums", "old"),
+		},
+		[]testEntry{
+			tarDir("./"),
+			tarFile("./same", "same"),
+			tarFile("./changed", "old"),
+			tarFile("./removed", "x"),
+			tarSymlink("./link", "same"),
+		},
+	)
+	newPkg := openPackage(
+		"Package: test\nVersion: 1.1\nArchitecture: all\nDepends: a (>= 2)\n",
+		[]testEntry{
+			{name: "./postinst", data: "#!/bin/sh\nset -e\necho new\n", mode: 0o755},
+			{name: "./config", data: "#!/bin/sh\n", mode: 0o644},
+			{name: "./postrm", data: "#!/bin/sh\n", mode: 0o755},
+			tarFile("./md5sums", "new"),
+		},
+		[]testEntry{
+			tarDir("./"),
+			tarFile("./same", "same"),
+			tarFile("./changed", "newer"),
+			tarFile("./added", "x"),
+			tarSymlink("./link", "changed"),
+		},
+	)
+
+	d, err := Diff(oldPkg, newPkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Empty() {
+		t.Fatal("packages reported the same")
+	}
+
+	wantFields := []*FieldChange{{Field: "Version", Change: ChangeChanged, Old: "1.0", New: "1.1"}}
+	if !reflect.DeepEqual(d.Fields, wantFields) {
+		t.Errorf("got fields %s", jsonString(t, d.Fields))
+	}
+	wantRelations := []*RelationChange{{Field: "Depends", Changed: []*ValueChange{{Old: "a (>= 1)", New: "a (>= 2)"}}}}
+	if !reflect.DeepEqual(d.Relations, wantRelations) {
+		t.Errorf("got relations %s", jsonString(t, d.Relations))
+	}
+
+	wantMembers := []*MemberChange{
+		{Name: "postinst", Change: ChangeChanged, Diff: "--- a/postinst\n+++ b/postinst\n@@ -1,3 +1,3 @@\n #!/bin/sh\n set -e\n-echo old\n+echo new\n"},
+		{Name: "prerm", Change: ChangeRemoved, Diff: "--- a/prerm\n+++ /dev/null\n@@ -1 +0,0 @@\n-#!/bin/sh\n"},
+		{Name: "config", Change: ChangeChanged, Mode: "-rwxr-xr-x -> -rw-r--r--"},
+		{Name: "postrm", Change: ChangeAdded, Diff: "--- /dev/null\n+++ b/postrm\n@@ -0,0 +1 @@\n+#!/bin/sh\n"},
+	}
+	if !reflect.DeepEqual(d.Members, wantMembers) {
+		t.Errorf("got members %s", jsonString(t, d.Members))
+	}
+
+	var files []string
+	for _, f := range d.Files {
+		files = append(files, f.Path+" "+f.Change)
+	}
+	wantFiles := []string{"added added", "changed changed", "link changed", "removed removed"}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("got files %q, want %q", files, wantFiles)
+	}
+	if len(d.Files) == 4 {
+		if want := []string{"size 3 -> 5", "sha256 changed"}; !reflect.DeepEqual(d.Files[1].Details, want) {
+			t.Errorf("changed file has details %q, want %q", d.Files[1].Details, want)
+		}
+		if want := []string{"link target same -> changed"}; !reflect.DeepEqual(d.Files[2].Details, want) {
+			t.Errorf("link has details %q, want %q", d.Files[2].Details, want)
+		}
+	}
+}
+
+func TestDiffSame(t *testing.T) {
+	data := []testEntry{tarDir("./"), tarFile("./a", "a")}
+	d, err := Diff(openTestPackage(t, data), openTestPackage(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Empty() {
+		t.Errorf("identical packages differ: %s", jsonString(t, d))
+	}
+}
+
+// jsonString formats v for test failures, as the structs are mostly pointers
+func jsonString(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,222 @@
+package deb
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// ELFTarget is the ELF class, byte order and machine of binaries for an architecture
+type ELFTarget struct {
+	Class   elf.Class
+	Data    elf.Data
+	Machine elf.Machine
+}
+
+// ELFTargets maps Debian architecture names to the kind of ELF binaries they run
+var ELFTargets = map[string]ELFTarget{
+	"amd64":    {elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_X86_64},
+	"i386":     {elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_386},
+	"arm64":    {elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_AARCH64},
+	"armhf":    {elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_ARM},
+	"armel":    {elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_ARM},
+	"ppc64el":  {elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_PPC64},
+	"ppc64":    {elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_PPC64},
+	"powerpc":  {elf.ELFCLASS32, elf.ELFDATA2MSB, elf.EM_PPC},
+	"s390x":    {elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_S390},
+	"riscv64":  {elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_RISCV},
+	"mips64el": {elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_MIPS},
+	"mipsel":   {elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_MIPS},
+}
+
+// ELFArchitectures returns the Debian architectures that run binaries for target, sorted
+func ELFArchitectures(target ELFTarget) []string {
+	var result []string
+	for arch, t := range ELFTargets {
+		if t == target {
+			result = append(result, arch)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ELFInfo is what Describe found out about an ELF executable or shared library
+type ELFInfo struct {
+	Type    string `json:"type"`
+	Class   string `json:"class"`
+	Machine string `json:"machine"`
+	// Architectures are the Debian architectures the binary is for, e.g. ["amd64"]
+	Architectures []string `json:"architectures,omitempty"`
+
+	Needed  []string `json:"needed,omitempty"`
+	SONAME  string   `json:"soname,omitempty"`
+	RPath   []string `json:"rpath,omitempty"`
+	RunPath []string `json:"runpath,omitempty"`
+	BuildID string   `json:"build_id,omitempty"`
+
+	// Stripped is true when there is no .symtab section
+	Stripped bool `json:"stripped"`
+	// PIE is true for position independent executables, and false for shared libraries
+	PIE bool `json:"pie"`
+	// RELRO is "none", "partial" (PT_GNU_RELRO) or "full" (PT_GNU_RELRO and immediate binding)
+	RELRO string `json:"relro"`
+	// NX is true when the stack isn't executable
+	NX bool `json:"nx"`
+}
+
+func isELFMIME(mime string) bool {
+	return strings.HasPrefix(mime, "application/x-executable") || strings.HasPrefix(mime, "application/x-sharedlib")
+}
+
+// InspectELF parses an ELF executable or shared library
+func InspectELF(data []byte) (*ELFInfo, error) {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &ELFInfo{
+		Type:          f.Type.String(),
+		Class:         f.Class.String(),
+		Machine:       f.Machine.String(),
+		Architectures: ELFArchitectures(ELFTarget{f.Class, f.Data, f.Machine}),
+		Stripped:      f.Section(".symtab") == nil,
+		RELRO:         "none",
+	}
+
+	// these fail on static binaries without a dynamic section, which just have none of them
+	info.Needed, _ = f.DynString(elf.DT_NEEDED)
+	if soname, _ := f.DynString(elf.DT_SONAME); len(soname) > 0 {
+		info.SONAME = soname[0]
+	}
+	info.RPath = splitPaths(f, elf.DT_RPATH)
+	info.RunPath = splitPaths(f, elf.DT_RUNPATH)
+
+	info.BuildID = buildID(f)
+
+	hasInterp := false
+	hasRELRO := false
+	hasGNUStack := false
+	for _, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			hasInterp = true
+		case elf.PT_GNU_RELRO:
+			hasRELRO = true
+		case elf.PT_GNU_STACK:
+			hasGNUStack = true
+			info.NX = p.Flags&elf.PF_X == 0
+		}
+	}
+	if !hasGNUStack {
+		// without PT_GNU_STACK the kernel assumes an executable stack
+		info.NX = false
+	}
+
+	flags, flags1, bindNow := dynFlags(f)
+	info.PIE = f.Type == elf.ET_DYN && (hasInterp || flags1&uint64(elf.DF_1_PIE) != 0)
+	if hasRELRO {
+		info.RELRO = "partial"
+		if bindNow || flags&uint64(elf.DF_BIND_NOW) != 0 || flags1&uint64(elf.DF_1_NOW) != 0 {
+			info.RELRO = "full"
+		}
+	}
+
+	return info, nil
+}
+
+func splitPaths(f *elf.File, tag elf.DynTag) []string {
+	values, _ := f.DynString(tag)
+	var result []string
+	for _, v := range values {
+		result = append(result, strings.Split(v, ":")...)
+	}
+	return result
+}
+
+// buildID reads the GNU build ID note, from the section if there is one or the program headers if not
+func buildID(f *elf.File) string {
+	var notes [][]byte
+	if s := f.Section(".note.gnu.build-id"); s != nil {
+		if data, err := s.Data(); err == nil {
+			notes = append(notes, data)
+		}
+	} else {
+		for _, p := range f.Progs {
+			if p.Type != elf.PT_NOTE {
+				continue
+			}
+			data := make([]byte, p.Filesz)
+			if _, err := p.ReadAt(data, 0); err == nil {
+				notes = append(notes, data)
+			}
+		}
+	}
+
+	const ntGNUBuildID = 3
+	for _, data := range notes {
+		for len(data) >= 12 {
+			nameSize := f.ByteOrder.Uint32(data[0:])
+			descSize := f.ByteOrder.Uint32(data[4:])
+			noteType := f.ByteOrder.Uint32(data[8:])
+			nameEnd := 12 + align4(nameSize)
+			descEnd := nameEnd + align4(descSize)
+			if descEnd > uint64(len(data)) {
+				break
+			}
+			name := strings.TrimRight(string(data[12:12+uint64(nameSize)]), "\x00")
+			if name == "GNU" && noteType == ntGNUBuildID {
+				return hex.EncodeToString(data[nameEnd : nameEnd+uint64(descSize)])
+			}
+			data = data[descEnd:]
+		}
+	}
+	return ""
+}
+
+func align4(n uint32) uint64 {
+	return (uint64(n) + 3) &^ 3
+}
+
+// dynFlags reads DT_FLAGS, DT_FLAGS_1 and whether DT_BIND_NOW is present from the dynamic section
+func dynFlags(f *elf.File) (flags uint64, flags1 uint64, bindNow bool) {
+	s := f.SectionByType(elf.SHT_DYNAMIC)
+	if s == nil {
+		return 0, 0, false
+	}
+	data, err := s.Data()
+	if err != nil {
+		return 0, 0, false
+	}
+
+	entrySize := 16
+	if f.Class == elf.ELFCLASS32 {
+		entrySize = 8
+	}
+	for ; len(data) >= entrySize; data = data[entrySize:] {
+		var tag elf.DynTag
+		var value uint64
+		if f.Class == elf.ELFCLASS32 {
+			tag = elf.DynTag(f.ByteOrder.Uint32(data[0:]))
+			value = uint64(f.ByteOrder.Uint32(data[4:]))
+		} else {
+			tag = elf.DynTag(f.ByteOrder.Uint64(data[0:]))
+			value = f.ByteOrder.Uint64(data[8:])
+		}
+		switch tag {
+		case elf.DT_NULL:
+			return flags, flags1, bindNow
+		case elf.DT_FLAGS:
+			flags = value
+		case elf.DT_FLAGS_1:
+			flags1 = value
+		case elf.DT_BIND_NOW:
+			bindNow = true
+		}
+	}
+	return flags, flags1, bindNow
+}
@@ -0,0 +1,427 @@
+package deb
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxExtractSize is the most Extract will write unless told otherwise
+	DefaultMaxExtractSize = 8 << 30
+	// DefaultMaxExtractRatio is how many times the size of the compressed data archive
+	// Extract will write unless told otherwise
+	DefaultMaxExtractRatio = 200
+	// DefaultMaxExtractEntries is how many data archive entries Extract will create unless told otherwise
+	DefaultMaxExtractEntries = 1 << 20
+	// entryCost is what each entry other than a regular file counts for against the size limits,
+	// so a bomb of empty entries is caught like one of large files. It is the size of a tar header.
+	entryCost = 512
+	// the ratio isn't applied below this size, so small packages of very compressible files are fine
+	minExtractRatioSize = 64 << 20
+)
+
+// ErrExtractLimit is returned, wrapped, when a package expands to more than ExtractOptions allows,
+// or its control archive to more than can reasonably be read into memory
+var ErrExtractLimit = errors.New("extraction size limit exceeded")
+
+// UnsafePathError is returned for entries that could write outside the extraction directory
+type UnsafePathError struct {
+	Name   string
+	Reason string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe path %q: %s", e.Name, e.Reason)
+}
+
+// ExtractOptions controls Extract
+type ExtractOptions struct {
+	// MaxSize is the total size of files to write before giving up, 0 meaning DefaultMaxExtractSize.
+	// Directories, links and other entries count for 512 bytes each.
+	MaxSize int64
+	// MaxRatio limits the total size of files to this many times the compressed data archive size,
+	// to catch decompression bombs. 0 means DefaultMaxExtractRatio, and negative means no limit.
+	MaxRatio int64
+	// MaxEntries is the number of entries to read before giving up, 0 meaning DefaultMaxExtractEntries
+	MaxEntries int
+	// PreserveMtimes sets modification times from the archive, except on symlinks
+	PreserveMtimes bool
+}
+
+// limit is the number of bytes that can be written for a data archive of compressedSize bytes
+func (o ExtractOptions) limit(compressedSize int64) int64 {
+	limit := o.MaxSize
+	if limit <= 0 {
+		limit = DefaultMaxExtractSize
+	}
+	ratio := o.MaxRatio
+	if ratio == 0 {
+		ratio = DefaultMaxExtractRatio
+	}
+	if ratio > 0 {
+		ratioLimit := ratio * compressedSize
+		if ratioLimit < minExtractRatioSize {
+			ratioLimit = minExtractRatioSize
+		}
+		if ratioLimit < limit {
+			limit = ratioLimit
+		}
+	}
+	return limit
+}
+
+// maxEntries is the number of entries that can be extracted
+func (o ExtractOptions) maxEntries() int {
+	if o.MaxEntries <= 0 {
+		return DefaultMaxExtractEntries
+	}
+	return o.MaxEntries
+}
+
+// ExtractResult counts what Extract wrote
+type ExtractResult struct {
+	Files     int   `json:"files"`
+	Dirs      int   `json:"dirs"`
+	Symlinks  int   `json:"symlinks"`
+	HardLinks int   `json:"hard_links"`
+	Bytes     int64 `json:"bytes"`
+	// Skipped are entries that can't be created without privileges, like device nodes and FIFOs
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Extract writes the data archive to dir, creating it if needed. Entry paths are checked
+// so nothing is written outside dir: absolute paths, ".." components, symlinks resolving
+// outside the package and entries beneath symlinks are all rejected with an UnsafePathError.
+// Hard links must be to files already extracted, not through symlinks. Absolute symlink
+// targets are left as they are, as dpkg treats them as relative to the root the package is
+// installed in. Setuid, setgid and sticky bits are not restored.
+// It consumes the data archive, so can't be used with Data.
+func (p *Package) Extract(dir string, opts ExtractOptions) (*ExtractResult, error) {
+	data, err := p.Data()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	limit, maxEntries := opts.limit(data.CompressedSize), opts.maxEntries()
+	result := &ExtractResult{}
+	// used is the total counted against limit: the size of files, and entryCost for everything else
+	var used int64
+	entries := 0
+
+	// directory modes and times are set last, so read-only directories can still be filled
+	type dirEntry struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirEntry
+	var symlinks []string
+
+	for {
+		hdr, err := data.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		entries++
+		if entries > maxEntries {
+			return result, fmt.Errorf("%w: more than %d entries", ErrExtractLimit, maxEntries)
+		}
+		cost := int64(entryCost)
+		if hdr.Typeflag == tar.TypeReg {
+			cost = hdr.Size
+		}
+		if used+cost > limit {
+			return result, fmt.Errorf("%w: %s would take the total past %d bytes", ErrExtractLimit, hdr.Name, limit)
+		}
+		used += cost
+
+		rel, err := entryPath(hdr.Name)
+		if err != nil {
+			return result, err
+		}
+		if rel == "" {
+			// the root directory, "./"
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := makeParents(dir, rel); err != nil {
+			return result, err
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := removeExisting(target, hdr.Name); err != nil {
+				return result, err
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := makeDir(target); err != nil {
+				return result, err
+			}
+			dirs = append(dirs, dirEntry{target, hdr.FileInfo().Mode().Perm(), hdr.ModTime})
+			result.Dirs++
+		case tar.TypeReg:
+			n, err := writeFile(target, data, hdr.FileInfo().Mode().Perm())
+			result.Bytes += n
+			if err != nil {
+				return result, fmt.Errorf("failed to write %s: %w", hdr.Name, err)
+			}
+			if opts.PreserveMtimes {
+				if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+					return result, err
+				}
+			}
+			result.Files++
+		case tar.TypeSymlink:
+			if err := checkSymlink(rel, hdr.Linkname); err != nil {
+				return result, err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return result, err
+			}
+			symlinks = append(symlinks, rel)
+			result.Symlinks++
+		case tar.TypeLink:
+			linkRel, err := entryPath(hdr.Linkname)
+			if err != nil {
+				return result, err
+			}
+			// Lstat follows symlinks in the parent directories, which could lead outside dir
+			if throughSymlink(dir, linkRel) {
+				return result, &UnsafePathError{hdr.Name, fmt.Sprintf("hard link to %s goes through a symlink", hdr.Linkname)}
+			}
+			linkTarget := filepath.Join(dir, filepath.FromSlash(linkRel))
+			if fi, err := os.Lstat(linkTarget); err != nil || !fi.Mode().IsRegular() {
+				return result, fmt.Errorf("hard link %s points to %s, which isn't an extracted file", hdr.Name, hdr.Linkname)
+			}
+			if err := os.Link(linkTarget, target); err != nil {
+				return result, err
+			}
+			result.HardLinks++
+		default:
+			result.Skipped = append(result.Skipped, hdr.Name)
+		}
+	}
+
+	// symlinks were checked as they were created, but that can be beaten by going through
+	// symlinks created later, so check where they point now they all exist
+	for _, rel := range symlinks {
+		if !withinTree(dir, rel, 0) {
+			os.Remove(filepath.Join(dir, filepath.FromSlash(rel)))
+			return result, &UnsafePathError{rel, "symlink points outside the package through other symlinks"}
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, d.mode); err != nil {
+			return result, err
+		}
+		if opts.PreserveMtimes {
+			if err := os.Chtimes(d.path, d.modTime, d.modTime); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ExtractControl writes the control archive members to dir, creating it if needed
+func (p *Package) ExtractControl(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, m := range p.ControlMembers {
+		rel, err := entryPath(m.Name)
+		if err != nil {
+			return err
+		}
+		if err := makeParents(dir, rel); err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := removeExisting(target, m.Name); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, m.Data, m.Mode.Perm()); err != nil {
+			return err
+		}
+		// WriteFile is subject to the umask
+		if err := os.Chmod(target, m.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryPath checks an archive path is relative and has no ".." components, returning it
+// normalized, e.g. "usr/bin/foo". The archive root is "".
+func entryPath(name string) (string, error) {
+	if strings.HasPrefix(name, "/") {
+		return "", &UnsafePathError{name, "absolute path"}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", &UnsafePathError{name, "path contains .."}
+		}
+	}
+	return NormalizePath(name), nil
+}
+
+// checkSymlink rejects relative symlink targets that resolve outside the package
+func checkSymlink(rel string, linkname string) error {
+	if path.IsAbs(linkname) {
+		return nil
+	}
+	resolved := path.Join(path.Dir(rel), linkname)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return &UnsafePathError{rel, fmt.Sprintf("symlink to %s points outside the package", linkname)}
+	}
+	return nil
+}
+
+// withinTree reports whether the relative path p stays inside dir, following the symlinks
+// in dir as it goes. Absolute symlinks are taken to be relative to dir, as with checkSymlink.
+func withinTree(dir string, p string, depth int) bool {
+	const maxSymlinks = 40
+	if depth > maxSymlinks {
+		return false
+	}
+	parts := strings.Split(p, "/")
+	var resolved []string
+	for i, part := range parts {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return false
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		resolved = append(resolved, part)
+		current := filepath.Join(dir, filepath.FromSlash(strings.Join(resolved, "/")))
+		fi, err := os.Lstat(current)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		link, err := os.Readlink(current)
+		if err != nil {
+			return false
+		}
+		// not cleaned, so the ".." components are walked through symlinks too
+		next := link
+		if !path.IsAbs(link) {
+			next = strings.Join(resolved[:len(resolved)-1], "/") + "/" + link
+		}
+		return withinTree(dir, next+"/"+strings.Join(parts[i+1:], "/"), depth+1)
+	}
+	return true
+}
+
+// makeParents creates the directories above rel in dir, refusing to go through symlinks
+// and so write somewhere an earlier entry pointed at
+func makeParents(dir string, rel string) error {
+	parts := strings.Split(rel, "/")
+	current := dir
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		fi, err := os.Lstat(current)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			if err := os.Mkdir(current, 0o755); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case fi.Mode()&os.ModeSymlink != 0:
+			return &UnsafePathError{rel, "parent directory is a symlink"}
+		case !fi.IsDir():
+			return fmt.Errorf("parent of %s is not a directory", rel)
+		}
+	}
+	return nil
+}
+
+// throughSymlink reports whether any of the directories above rel in dir is a symlink
+func throughSymlink(dir string, rel string) bool {
+	parts := strings.Split(rel, "/")
+	current := dir
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		fi, err := os.Lstat(current)
+		if err != nil {
+			// nothing was extracted there, so there's nothing to link to either
+			return false
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// makeDir creates a directory entry, which may already exist as the parent of an earlier entry
+func makeDir(target string) error {
+	fi, err := os.Lstat(target)
+	if err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Mkdir(target, 0o755)
+}
+
+// removeExisting removes whatever is at target so an entry can replace it, as tar does.
+// Directories aren't replaced.
+func removeExisting(target string, name string) error {
+	fi, err := os.Lstat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s already exists as a directory", name)
+	}
+	return os.Remove(target)
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) (int64, error) {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, os.Chmod(target, mode)
+}
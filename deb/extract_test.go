@@ -0,0 +1,310 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/porty/deb-info/ar"
+)
+
+// testEntry is a tar entry for makePackage. Regular files are the default type.
+type testEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	mode     int64
+	data     string
+}
+
+func tarDir(name string) testEntry               { return testEntry{name: name, typeflag: tar.TypeDir} }
+func tarFile(name string, data string) testEntry { return testEntry{name: name, data: data} }
+func tarSymlink(name string, to string) testEntry {
+	return testEntry{name: name, typeflag: tar.TypeSymlink, linkname: to}
+}
+func tarHardLink(name string, to string) testEntry {
+	return testEntry{name: name, typeflag: tar.TypeLink, linkname: to}
+}
+
+const testControl = "Package: test\nVersion: 1.0\nArchitecture: all\n"
+
+// makeTar returns a gzipped tar of entries
+func makeTar(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     e.mode,
+			Size:     int64(len(e.data)),
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+			if hdr.Typeflag == tar.TypeDir {
+				hdr.Mode = 0o755
+			}
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// makePackage builds a package from gzipped control and data archives
+func makePackage(t *testing.T, control []byte, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := ar.NewWriter(&buf)
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+		{"data.tar.gz", data},
+	} {
+		err := w.WriteFile(&ar.FileInfo{Name: m.name, Mode: 0o100644, Size: int64(len(m.data)), Reader: bytes.NewReader(m.data)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func openTestPackage(t *testing.T, data []testEntry) *Package {
+	t.Helper()
+	control := makeTar(t, []testEntry{tarFile("./control", testControl)})
+	pkg, err := Open(bytes.NewReader(makePackage(t, control, makeTar(t, data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestExtract(t *testing.T) {
+	out := t.TempDir()
+	pkg := openTestPackage(t, []testEntry{
+		tarDir("./"),
+		tarDir("./usr/"),
+		tarDir("./usr/bin/"),
+		{name: "./usr/bin/tool", data: "#!/bin/sh\n", mode: 0o4755},
+		tarHardLink("./usr/bin/tool2", "./usr/bin/tool"),
+		tarSymlink("./usr/bin/rel", "tool"),
+		tarSymlink("./usr/bin/abs", "/usr/bin/tool"),
+		tarFile("./etc/implied-parent", "x"),
+	})
+	result, err := pkg.Extract(out, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 2 || result.Dirs != 2 || result.Symlinks != 2 || result.HardLinks != 1 || result.Bytes != 11 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	fi, err := os.Stat(filepath.Join(out, "usr/bin/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0o755 {
+		t.Errorf("tool has mode %s, want setuid dropped", fi.Mode())
+	}
+	linked, err := os.Stat(filepath.Join(out, "usr/bin/tool2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(fi, linked) {
+		t.Error("tool2 isn't a hard link to tool")
+	}
+	if target, err := os.Readlink(filepath.Join(out, "usr/bin/abs")); err != nil || target != "/usr/bin/tool" {
+		t.Errorf("abs links to %q, %v", target, err)
+	}
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	// replaced by the directory holding the extraction directory
+	const outside = "OUTSIDE"
+	for _, test := range []struct {
+		name    string
+		entries []testEntry
+	}{
+		{"absolute path", []testEntry{tarFile("/etc/evil", "x")}},
+		{"dot dot", []testEntry{tarFile("./../evil", "x")}},
+		{"dot dot inside", []testEntry{tarDir("./a/"), tarFile("./a/../../evil", "x")}},
+		{"relative symlink out", []testEntry{tarSymlink("./a", "../evil")}},
+		{"relative symlink out from subdirectory", []testEntry{tarDir("./a/"), tarSymlink("./a/b", "../../evil")}},
+		{"write through symlink", []testEntry{tarSymlink("./a", outside), tarFile("./a/evil", "x")}},
+		{"write through relative symlink", []testEntry{tarDir("./d/"), tarSymlink("./a", "d"), tarFile("./a/evil", "x")}},
+		{"symlink chain", []testEntry{tarDir("./d/"), tarSymlink("./d/up", ".."), tarSymlink("./x", "d/up/..")}},
+		{"hard link absolute", []testEntry{tarHardLink("./a", "/etc/passwd")}},
+		{"hard link dot dot", []testEntry{tarHardLink("./a", "../evil")}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			parent := t.TempDir()
+			out := filepath.Join(parent, "out")
+			for i := range test.entries {
+				if test.entries[i].linkname == outside {
+					test.entries[i].linkname = parent
+				}
+			}
+			_, err := openTestPackage(t, test.entries).Extract(out, ExtractOptions{})
+			var unsafe *UnsafePathError
+			if !errors.As(err, &unsafe) {
+				t.Fatalf("expected an UnsafePathError, got %v", err)
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "evil")); err == nil {
+				t.Error("wrote outside the extraction directory")
+			}
+		})
+	}
+}
+
+// TestExtractHardLinkThroughSymlink checks a hard link can't take a file from outside the
+// extraction directory by going through an absolute symlink
+func TestExtractHardLinkThroughSymlink(t *testing.T) {
+	victimDir := t.TempDir()
+	victim := filepath.Join(victimDir, "secret")
+	if err := os.WriteFile(victim, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	pkg := openTestPackage(t, []testEntry{
+		tarSymlink("./a", victimDir),
+		tarHardLink("./b", "./a/secret"),
+	})
+	_, err := pkg.Extract(out, ExtractOptions{})
+	var unsafe *UnsafePathError
+	if !errors.As(err, &unsafe) {
+		t.Fatalf("expected an UnsafePathError, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(out, "b")); err == nil {
+		t.Error("hard link was created")
+	}
+}
+
+// manyEntries returns n entries made by entry, numbered from 0
+func manyEntries(n int, entry func(name string) testEntry) []testEntry {
+	entries := make([]testEntry, n)
+	for i := range entries {
+		entries[i] = entry(fmt.Sprintf("./e%d", i))
+	}
+	return entries
+}
+
+func emptyFile(name string) testEntry    { return tarFile(name, "") }
+func symlinkEntry(name string) testEntry { return tarSymlink(name, "target") }
+
+func TestExtractLimit(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		entries []testEntry
+		opts    ExtractOptions
+		// ok is whether the package should extract, otherwise ErrExtractLimit is expected
+		ok bool
+	}{
+		{"file past max size", []testEntry{tarFile("./small", "x"), tarFile("./big", strings.Repeat("x", 100))}, ExtractOptions{MaxSize: 50}, false},
+		{"files up to max size", []testEntry{tarFile("./a", strings.Repeat("x", 25)), tarFile("./b", strings.Repeat("x", 25))}, ExtractOptions{MaxSize: 50}, true},
+		{"many empty files", manyEntries(2000, emptyFile), ExtractOptions{}, true},
+		{"empty files past max entries", manyEntries(101, emptyFile), ExtractOptions{MaxEntries: 100}, false},
+		{"empty files up to max entries", manyEntries(100, emptyFile), ExtractOptions{MaxEntries: 100}, true},
+		{"directories past max entries", manyEntries(101, tarDir), ExtractOptions{MaxEntries: 100}, false},
+		{"root directory counts as an entry", append([]testEntry{tarDir("./")}, manyEntries(100, tarDir)...), ExtractOptions{MaxEntries: 100}, false},
+		{"directories past max size", manyEntries(11, tarDir), ExtractOptions{MaxSize: 10 * entryCost}, false},
+		{"directories up to max size", manyEntries(10, tarDir), ExtractOptions{MaxSize: 10 * entryCost}, true},
+		{"symlinks past max size", manyEntries(11, symlinkEntry), ExtractOptions{MaxSize: 10 * entryCost}, false},
+		{"hard links past max size", append([]testEntry{tarFile("./target", "")}, manyEntries(11, func(name string) testEntry {
+			return tarHardLink(name, "./target")
+		})...), ExtractOptions{MaxSize: 10 * entryCost}, false},
+		{"entries and files past max size", []testEntry{tarDir("./d/"), tarFile("./d/f", strings.Repeat("x", 600))}, ExtractOptions{MaxSize: 1000}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := openTestPackage(t, test.entries).Extract(t.TempDir(), test.opts)
+			if test.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrExtractLimit) {
+				t.Fatalf("expected ErrExtractLimit, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractOptionsLimits(t *testing.T) {
+	for _, test := range []struct {
+		opts       ExtractOptions
+		compressed int64
+		limit      int64
+		entries    int
+	}{
+		{ExtractOptions{}, 100 << 10, minExtractRatioSize, DefaultMaxExtractEntries},
+		{ExtractOptions{}, 1 << 30, DefaultMaxExtractSize, DefaultMaxExtractEntries},
+		{ExtractOptions{}, 1 << 25, DefaultMaxExtractRatio << 25, DefaultMaxExtractEntries},
+		{ExtractOptions{MaxRatio: -1}, 1 << 20, DefaultMaxExtractSize, DefaultMaxExtractEntries},
+		{ExtractOptions{MaxSize: 1000, MaxRatio: -1}, 1 << 20, 1000, DefaultMaxExtractEntries},
+		{ExtractOptions{MaxSize: 1 << 40, MaxRatio: 1000}, 1 << 20, 1000 << 20, DefaultMaxExtractEntries},
+		{ExtractOptions{MaxEntries: 10}, 100 << 10, minExtractRatioSize, 10},
+		{ExtractOptions{MaxEntries: -1}, 100 << 10, minExtractRatioSize, DefaultMaxExtractEntries},
+	} {
+		if limit := test.opts.limit(test.compressed); limit != test.limit {
+			t.Errorf("%+v limit for %d bytes is %d, want %d", test.opts, test.compressed, limit, test.limit)
+		}
+		if entries := test.opts.maxEntries(); entries != test.entries {
+			t.Errorf("%+v allows %d entries, want %d", test.opts, entries, test.entries)
+		}
+	}
+}
+
+func TestExtractControl(t *testing.T) {
+	control := makeTar(t, []testEntry{
+		tarFile("./control", testControl),
+		{name: "./postinst", data: "#!/bin/sh\n", mode: 0o755},
+	})
+	pkg, err := Open(bytes.NewReader(makePackage(t, control, makeTar(t, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := pkg.ExtractControl(out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "control"))
+	if err != nil || string(data) != testControl {
+		t.Errorf("control is %q, %v", data, err)
+	}
+	fi, err := os.Stat(filepath.Join(out, "postinst"))
+	if err != nil || fi.Mode() != 0o755 {
+		t.Errorf("postinst has mode %v, %v", fi, err)
+	}
+}
@@ -0,0 +1,201 @@
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Entry types, as FileInfo.Type
+const (
+	TypeFile     = "file"
+	TypeDir      = "dir"
+	TypeSymlink  = "symlink"
+	TypeHardLink = "hardlink"
+	TypeChar     = "char"
+	TypeBlock    = "block"
+	TypeFIFO     = "fifo"
+	TypeUnknown  = "unknown"
+)
+
+// FileInfo describes an entry of the data archive
+type FileInfo struct {
+	Name string `json:"name"`
+	// Type is one of the Type constants, e.g. TypeFile
+	Type string `json:"type"`
+	// directories will have Size=0, and will be omitted
+	// empty files will also be omitted :(
+	Size int64  `json:"size,omitempty"`
+	Mode string `json:"mode,omitempty"`
+
+	UID int `json:"uid"`
+	GID int `json:"gid"`
+	// User and Group are the owner names, if the archive records them
+	User    string    `json:"user,omitempty"`
+	Group   string    `json:"group,omitempty"`
+	ModTime time.Time `json:"mtime"`
+
+	// MIME is the detected type of regular files
+	MIME string `json:"mime,omitempty"`
+	// LinkTarget is where symlinks point, or the earlier entry hard links share contents with
+	LinkTarget string `json:"link_target,omitempty"`
+	// Device is the device number of character and block devices
+	Device *Device `json:"device,omitempty"`
+
+	// hex digests of regular files, if requested with DescribeOptions.Hashes
+	MD5    string `json:"md5,omitempty"`
+	SHA1   string `json:"sha1,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	SHA512 string `json:"sha512,omitempty"`
+
+	StaticLibrary *StaticLibrary `json:"static_library,omitempty"`
+	ELF           *ELFInfo       `json:"elf,omitempty"`
+}
+
+// Device is a device node's number
+type Device struct {
+	Major int64 `json:"major"`
+	Minor int64 `json:"minor"`
+}
+
+// static libraries and ELF files are read into memory to inspect them, so don't try with anything huge
+const maxInspectSize = 256 * 1024 * 1024
+
+// OwnedByRoot reports whether the entry is owned by root:root, as nearly everything in a package should be
+func (fi *FileInfo) OwnedByRoot() bool {
+	return fi.UID == 0 && fi.GID == 0
+}
+
+// Owner formats the owner as "user:group (uid:gid)", or just the IDs where the archive has no names
+func (fi *FileInfo) Owner() string {
+	ids := fmt.Sprintf("%d:%d", fi.UID, fi.GID)
+	if fi.User == "" && fi.Group == "" {
+		return ids
+	}
+	user := fi.User
+	if user == "" {
+		user = strconv.Itoa(fi.UID)
+	}
+	group := fi.Group
+	if group == "" {
+		group = strconv.Itoa(fi.GID)
+	}
+	return fmt.Sprintf("%s:%s (%s)", user, group, ids)
+}
+
+// DescribeOptions controls the optional, more expensive, parts of Describe
+type DescribeOptions struct {
+	// StaticLibraries reads .a files to list their objects and symbols
+	StaticLibraries bool
+	// ELF parses executables and shared libraries, see ELFInfo
+	ELF bool
+	// Hashes are the names of the hashes to compute for regular files, see HashNames
+	Hashes []string
+}
+
+// InspectError is returned by Describe, along with the FileInfo, when a static library or
+// ELF file can't be parsed. Any other error means the entry couldn't be read.
+type InspectError struct {
+	Err error
+}
+
+func (e *InspectError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InspectError) Unwrap() error {
+	return e.Err
+}
+
+// Describe builds the FileInfo for hdr, reading the entry contents from r.
+// If a static library or ELF file can't be parsed the FileInfo is still returned, along with
+// an *InspectError. If the entry can't be read it returns nil and the error, and the rest of
+// the archive can't be read either.
+func Describe(hdr *tar.Header, r io.Reader, opts DescribeOptions) (*FileInfo, error) {
+	fi := &FileInfo{
+		Name: hdr.Name,
+		Size: hdr.Size,
+		Mode: hdr.FileInfo().Mode().String(),
+
+		UID:     hdr.Uid,
+		GID:     hdr.Gid,
+		User:    hdr.Uname,
+		Group:   hdr.Gname,
+		ModTime: hdr.ModTime.UTC(),
+	}
+
+	var err error
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		fi.Type = TypeDir
+	case tar.TypeSymlink:
+		fi.Type = TypeSymlink
+		fi.LinkTarget = hdr.Linkname
+	case tar.TypeLink:
+		fi.Type = TypeHardLink
+		fi.LinkTarget = hdr.Linkname
+	case tar.TypeChar, tar.TypeBlock:
+		fi.Type = TypeChar
+		if hdr.Typeflag == tar.TypeBlock {
+			fi.Type = TypeBlock
+		}
+		fi.Device = &Device{
+			Major: hdr.Devmajor,
+			Minor: hdr.Devminor,
+		}
+	case tar.TypeFifo:
+		fi.Type = TypeFIFO
+	case tar.TypeReg:
+		fi.Type = TypeFile
+		var hashes *multiHash
+		if len(opts.Hashes) > 0 {
+			// hash everything read for MIME detection, then the rest of the file
+			hashes = newMultiHash(opts.Hashes)
+			r = io.TeeReader(r, hashes)
+		}
+
+		br := bufio.NewReader(r)
+		r = br
+		magic, _ := br.Peek(len(elf.ELFMAG))
+		inspectStaticLibrary := opts.StaticLibraries && isStaticLibrary(hdr.Name)
+		inspectELF := opts.ELF && string(magic) == elf.ELFMAG
+		if (inspectStaticLibrary || inspectELF) && hdr.Size <= maxInspectSize {
+			data, readErr := io.ReadAll(br)
+			if readErr != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, readErr)
+			}
+			fi.MIME = mimetype.Detect(data).String()
+			if inspectStaticLibrary {
+				fi.StaticLibrary, err = ReadStaticLibrary(data)
+				if err != nil {
+					err = &InspectError{Err: fmt.Errorf("failed to read static library %s: %w", hdr.Name, err)}
+				}
+			}
+			if inspectELF && isELFMIME(fi.MIME) {
+				fi.ELF, err = InspectELF(data)
+				if err != nil {
+					err = &InspectError{Err: fmt.Errorf("failed to parse ELF file %s: %w", hdr.Name, err)}
+				}
+			}
+		} else if mime, _ := mimetype.DetectReader(br); mime != nil {
+			fi.MIME = mime.String()
+		}
+
+		if hashes != nil {
+			if _, copyErr := io.Copy(io.Discard, r); copyErr != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, copyErr)
+			}
+			hashes.apply(fi)
+		}
+	default:
+		fi.Type = TypeUnknown
+	}
+
+	return fi, err
+}
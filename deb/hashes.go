@@ -0,0 +1,93 @@
+package deb
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// HashNames are the hashes Describe can compute, for DescribeOptions.Hashes
+var HashNames = []string{"md5", "sha1", "sha256", "sha512"}
+
+var hashFuncs = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ParseHashNames parses a comma separated list of hash names, e.g. "md5,sha256"
+func ParseHashNames(s string) ([]string, error) {
+	var result []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := hashFuncs[name]; !ok {
+			return nil, fmt.Errorf("unknown hash %q, expected one of %s", name, strings.Join(HashNames, ", "))
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// multiHash computes several hashes in one pass
+type multiHash struct {
+	names  []string
+	hashes []hash.Hash
+}
+
+func newMultiHash(names []string) *multiHash {
+	m := &multiHash{
+		names: names,
+	}
+	for _, name := range names {
+		m.hashes = append(m.hashes, hashFuncs[name]())
+	}
+	return m
+}
+
+func (m *multiHash) Write(b []byte) (int, error) {
+	for _, h := range m.hashes {
+		h.Write(b)
+	}
+	return len(b), nil
+}
+
+// apply sets the hash fields of fi
+func (m *multiHash) apply(fi *FileInfo) {
+	for i, name := range m.names {
+		sum := hex.EncodeToString(m.hashes[i].Sum(nil))
+		switch name {
+		case "md5":
+			fi.MD5 = sum
+		case "sha1":
+			fi.SHA1 = sum
+		case "sha256":
+			fi.SHA256 = sum
+		case "sha512":
+			fi.SHA512 = sum
+		}
+	}
+}
+
+// Hash returns the named hash of the file, or "" if it wasn't computed
+func (fi *FileInfo) Hash(name string) string {
+	switch name {
+	case "md5":
+		return fi.MD5
+	case "sha1":
+		return fi.SHA1
+	case "sha256":
+		return fi.SHA256
+	case "sha512":
+		return fi.SHA512
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package deb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/porty/deb-info/ar"
+)
+
+// RecompressOptions controls Recompress
+type RecompressOptions struct {
+	// Control and Data are the compression for each archive: "gzip", "xz", "zstd" or "none",
+	// or empty to copy the archive as it is
+	Control string
+	Data    string
+	// ControlLevel and DataLevel are the compression levels, 0 for the default
+	ControlLevel int
+	DataLevel    int
+}
+
+// RecompressedMember describes a package member copied by Recompress
+type RecompressedMember struct {
+	OldName        string
+	NewName        string
+	OldCompression string
+	NewCompression string
+	OldSize        int64
+	NewSize        int64
+}
+
+// Recompress copies the package in r to w with its control and data archives recompressed.
+// The tar streams are passed through unchanged, only their compression differs, and the
+// other members are copied byte for byte. It returns what happened to each member.
+func Recompress(r io.Reader, w io.Writer, opts RecompressOptions) ([]*RecompressedMember, error) {
+	targets := map[string]*compressor{}
+	levels := map[string]int{"control": opts.ControlLevel, "data": opts.DataLevel}
+	for base, name := range map[string]string{"control": opts.Control, "data": opts.Data} {
+		if name == "" {
+			if levels[base] != 0 {
+				return nil, fmt.Errorf("%s compression level given without a compression", base)
+			}
+			continue
+		}
+		c, err := findCompressor(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkLevel(levels[base]); err != nil {
+			return nil, err
+		}
+		targets[base] = c
+	}
+
+	if err := ar.ReadSignature(r); err != nil {
+		return nil, fmt.Errorf("failed to read Debian package header: %w", err)
+	}
+	archive, aw := ar.NewReader(r), ar.NewWriter(w)
+
+	var members []*RecompressedMember
+	for {
+		fi, err := archive.ReadFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		member := &RecompressedMember{OldName: fi.Name, NewName: fi.Name, OldSize: fi.Size, NewSize: fi.Size}
+		members = append(members, member)
+
+		var base string
+		switch {
+		case strings.HasPrefix(fi.Name, "control.tar"):
+			base = "control"
+		case strings.HasPrefix(fi.Name, "data.tar"):
+			base = "data"
+		}
+		c := targets[base]
+		if c == nil {
+			if err := aw.WriteFile(fi); err != nil {
+				return nil, fmt.Errorf("failed to copy %s: %w", fi.Name, err)
+			}
+			continue
+		}
+
+		if err := recompressMember(aw, fi, base, c, levels[base], member); err != nil {
+			return nil, fmt.Errorf("failed to recompress %s: %w", fi.Name, err)
+		}
+	}
+	return members, aw.Close()
+}
+
+// recompressMember writes the archive fi with the compressor c, filling in the rest of member
+func recompressMember(aw *ar.Writer, fi *ar.FileInfo, base string, c *compressor, level int, member *RecompressedMember) error {
+	r, compression, err := openTar(fi, base)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	member.OldCompression = compression
+	member.NewCompression = c.name
+
+	// the compressed size has to be known before it can be added to the package
+	tmp, err := os.CreateTemp("", "deb-info-"+base+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	cw, err := c.create(tmp, level)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(cw, r); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	member.NewName = base + ".tar" + c.ext
+	member.NewSize = size
+	return aw.WriteFile(&ar.FileInfo{
+		Name:   member.NewName,
+		Mod:    fi.Mod,
+		Owner:  fi.Owner,
+		Group:  fi.Group,
+		Mode:   fi.Mode,
+		Size:   size,
+		Reader: tmp,
+	})
+}
@@ -0,0 +1,175 @@
+package deb
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/porty/deb-info/ar"
+)
+
+// tarStreams returns the decompressed control and data archives of the package in data,
+// by base name, with the compression of each
+func tarStreams(t *testing.T, data []byte) (map[string][]byte, map[string]string) {
+	t.Helper()
+	r := bytes.NewReader(data)
+	if err := ar.ReadSignature(r); err != nil {
+		t.Fatal(err)
+	}
+	archive := ar.NewReader(r)
+	streams, compressions := map[string][]byte{}, map[string]string{}
+	for {
+		fi, err := archive.ReadFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := strings.SplitN(fi.Name, ".", 2)[0]
+		if base != "control" && base != "data" {
+			continue
+		}
+		tr, compression, err := openTar(fi, base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream, err := io.ReadAll(tr)
+		tr.Close()
+		if err != nil {
+			t.Fatalf("failed to decompress %s: %v", fi.Name, err)
+		}
+		streams[base] = stream
+		compressions[base] = compression
+	}
+	return streams, compressions
+}
+
+func TestRecompress(t *testing.T) {
+	for _, test := range []struct {
+		from string
+		opts RecompressOptions
+	}{
+		{"gzip", RecompressOptions{Control: "xz", Data: "zstd"}},
+		{"xz", RecompressOptions{Control: "gzip", ControlLevel: 9, Data: "none"}},
+		{"zstd", RecompressOptions{Control: "none", Data: "gzip", DataLevel: 1}},
+		{"none", RecompressOptions{Control: "zstd", ControlLevel: 19, Data: "xz", DataLevel: 9}},
+		{"gzip", RecompressOptions{Data: "gzip", DataLevel: 9}},
+		{"xz", RecompressOptions{Control: "xz"}},
+	} {
+		name := test.from + " to " + test.opts.Control + "," + test.opts.Data
+		t.Run(name, func(t *testing.T) {
+			original := buildPackage(t, BuildOptions{Compression: test.from, ModTime: time.Unix(1600000000, 0)})
+			var out bytes.Buffer
+			members, err := Recompress(bytes.NewReader(original), &out, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the tar streams are the same, just compressed differently
+			before, _ := tarStreams(t, original)
+			after, compressions := tarStreams(t, out.Bytes())
+			for _, base := range []string{"control", "data"} {
+				if !bytes.Equal(after[base], before[base]) {
+					t.Errorf("%s archive changed", base)
+				}
+			}
+			want := map[string]string{"control": test.opts.Control, "data": test.opts.Data}
+			for base, compression := range want {
+				if compression == "" {
+					compression = test.from
+				}
+				if compressions[base] != compression {
+					t.Errorf("%s archive is compressed with %s, want %s", base, compressions[base], compression)
+				}
+			}
+
+			names, contents := arMembers(t, out.Bytes())
+			if len(members) != len(names) {
+				t.Fatalf("got %d members reported for %q", len(members), names)
+			}
+			_, originalContents := arMembers(t, original)
+			for i, m := range members {
+				if m.NewName != names[i] || m.NewSize != int64(len(contents[m.NewName])) || m.OldSize != int64(len(originalContents[m.OldName])) {
+					t.Errorf("member %d reported as %+v, written as %s of %d bytes", i, m, names[i], len(contents[names[i]]))
+				}
+				base := strings.SplitN(m.OldName, ".", 2)[0]
+				if want[base] == "" {
+					// copied as it was
+					if m.OldName != m.NewName || m.OldCompression != "" || m.NewCompression != "" || !bytes.Equal(contents[m.NewName], originalContents[m.OldName]) {
+						t.Errorf("member %+v wasn't copied as it was", m)
+					}
+					continue
+				}
+				if m.OldCompression != test.from || m.NewCompression != want[base] {
+					t.Errorf("member %s reported as %s to %s", m.OldName, m.OldCompression, m.NewCompression)
+				}
+			}
+
+			pkg, err := Open(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pkg.Control != buildControl {
+				t.Errorf("got control %q", pkg.Control)
+			}
+		})
+	}
+}
+
+func TestRecompressNames(t *testing.T) {
+	original := buildPackage(t, BuildOptions{Compression: "gzip"})
+	for _, test := range []struct {
+		compression string
+		name        string
+	}{
+		{"gzip", "data.tar.gz"},
+		{"xz", "data.tar.xz"},
+		{"zstd", "data.tar.zst"},
+		{"none", "data.tar"},
+	} {
+		members, err := Recompress(bytes.NewReader(original), io.Discard, RecompressOptions{Data: test.compression})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 3 || members[2].OldName != "data.tar.gz" || members[2].NewName != test.name {
+			t.Errorf("%s: got members %s", test.compression, jsonString(t, members))
+		}
+	}
+}
+
+func TestRecompressErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		opts    RecompressOptions
+		message string
+	}{
+		{"unknown compression", RecompressOptions{Data: "lz4"}, "lz4"},
+		{"read-only compression", RecompressOptions{Control: "bzip2"}, "bzip2"},
+		{"level too high", RecompressOptions{Data: "gzip", DataLevel: 10}, "level"},
+		{"level too low", RecompressOptions{Control: "zstd", ControlLevel: -1}, "level"},
+		{"level without levels", RecompressOptions{Data: "none", DataLevel: 1}, "no levels"},
+		{"level without compression", RecompressOptions{DataLevel: 3}, "without a compression"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			original := buildPackage(t, BuildOptions{Compression: "gzip"})
+			var out bytes.Buffer
+			_, err := Recompress(bytes.NewReader(original), &out, test.opts)
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected an error containing %q, got %v", test.message, err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("%d bytes written despite the error", out.Len())
+			}
+		})
+	}
+
+	t.Run("not a package", func(t *testing.T) {
+		_, err := Recompress(strings.NewReader("not an archive"), io.Discard, RecompressOptions{Data: "xz"})
+		if err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package deb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/porty/deb-info/deb822"
+	"github.com/porty/deb-info/version"
+)
+
+// RelationFields are the fields holding package relationships
+var RelationFields = []string{
+	"Pre-Depends",
+	"Depends",
+	"Recommends",
+	"Suggests",
+	"Enhances",
+	"Breaks",
+	"Conflicts",
+	"Replaces",
+	"Provides",
+	"Built-Using",
+}
+
+// Relations is a relationship field: every element must be satisfied
+type Relations []Alternatives
+
+// Alternatives are relations separated by "|": any one of them satisfies the requirement
+type Alternatives []*Relation
+
+// Relation is a single package reference, e.g. "libc6:any (>= 2.34) [amd64] <!nocheck>"
+type Relation struct {
+	Name string `json:"name"`
+	// ArchQualifier is the part after ":" in the name, e.g. "any" or "native"
+	ArchQualifier string `json:"arch_qualifier,omitempty"`
+	// Operator is one of "<<", "<=", "=", ">=" and ">>", with the obsolete "<" and ">" read as "<=" and ">="
+	Operator string `json:"operator,omitempty"`
+	Version  string `json:"version,omitempty"`
+	// Architectures restricts the relation to (or, with a "!" prefix, away from) architectures
+	Architectures []string `json:"architectures,omitempty"`
+	// Profiles are build profile restrictions: the relation applies if any of the
+	// lists match, and a list matches if all its terms do
+	Profiles [][]string `json:"profiles,omitempty"`
+}
+
+// ParseControlRelations parses every relationship field present in a control paragraph
+func ParseControlRelations(control *deb822.Paragraph) (map[string]Relations, error) {
+	result := map[string]Relations{}
+	for _, name := range RelationFields {
+		value, ok := control.Get(name)
+		if !ok {
+			continue
+		}
+		relations, err := ParseRelations(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		result[name] = relations
+	}
+	return result, nil
+}
+
+// ParseRelations parses the value of a relationship field such as Depends
+func ParseRelations(value string) (Relations, error) {
+	var result Relations
+	for _, group := range strings.Split(value, ",") {
+		if strings.TrimSpace(group) == "" {
+			// allow trailing commas and line folding
+			continue
+		}
+		var alternatives Alternatives
+		for _, part := range strings.Split(group, "|") {
+			r, err := parseRelation(part)
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, r)
+		}
+		result = append(result, alternatives)
+	}
+	return result, nil
+}
+
+// relationParser reads a single relation, a character at a time
+type relationParser struct {
+	s   string
+	pos int
+}
+
+func (p *relationParser) skipSpace() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *relationParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// until returns the text up to the next of the stop characters, or the end
+func (p *relationParser) until(stop string) string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(stop, rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// while returns the text made up of the allowed characters
+func (p *relationParser) while(allowed string) string {
+	start := p.pos
+	for p.pos < len(p.s) && strings.ContainsRune(allowed, rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// expect consumes c, returning an error if it isn't next
+func (p *relationParser) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("expected %q in relation %q", c, strings.TrimSpace(p.s))
+	}
+	p.pos++
+	return nil
+}
+
+func parseRelation(s string) (*Relation, error) {
+	p := &relationParser{s: s}
+	r := &Relation{}
+
+	p.skipSpace()
+	name := p.until(" \t\n(<[")
+	if name == "" {
+		return nil, fmt.Errorf("missing package name in relation %q", strings.TrimSpace(s))
+	}
+	r.Name, r.ArchQualifier, _ = strings.Cut(name, ":")
+	p.skipSpace()
+
+	if p.peek() == '(' {
+		p.pos++
+		p.skipSpace()
+		op := p.while("<=>")
+		switch op {
+		case "<<", "<=", "=", ">=", ">>":
+			r.Operator = op
+		case "<":
+			r.Operator = "<="
+		case ">":
+			r.Operator = ">="
+		default:
+			return nil, fmt.Errorf("invalid version operator %q in relation %q", op, strings.TrimSpace(s))
+		}
+		p.skipSpace()
+		r.Version = strings.TrimSpace(p.until(")"))
+		if r.Version == "" {
+			return nil, fmt.Errorf("missing version in relation %q", strings.TrimSpace(s))
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+	}
+
+	if p.peek() == '[' {
+		p.pos++
+		r.Architectures = strings.Fields(p.until("]"))
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+	}
+
+	for p.peek() == '<' {
+		p.pos++
+		r.Profiles = append(r.Profiles, strings.Fields(p.until(">")))
+		if err := p.expect('>'); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+	}
+
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected %q in relation %q", p.s[p.pos:], strings.TrimSpace(s))
+	}
+	return r, nil
+}
+
+// String formats the relation as it would appear in a control file
+func (r *Relation) String() string {
+	var b strings.Builder
+	b.WriteString(r.Name)
+	if r.ArchQualifier != "" {
+		b.WriteString(":" + r.ArchQualifier)
+	}
+	if r.Operator != "" {
+		fmt.Fprintf(&b, " (%s %s)", r.Operator, r.Version)
+	}
+	if len(r.Architectures) > 0 {
+		fmt.Fprintf(&b, " [%s]", strings.Join(r.Architectures, " "))
+	}
+	for _, profile := range r.Profiles {
+		fmt.Fprintf(&b, " <%s>", strings.Join(profile, " "))
+	}
+	return b.String()
+}
+
+// String formats the alternatives as they would appear in a control file
+func (a Alternatives) String() string {
+	parts := make([]string, len(a))
+	for i, r := range a {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// String formats the relations as they would appear in a control file
+func (rs Relations) String() string {
+	parts := make([]string, len(rs))
+	for i, a := range rs {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SatisfiedBy reports whether the package described by control satisfies the relation,
+// either itself or through its Provides field. Architecture qualifiers and restrictions are not considered.
+func (r *Relation) SatisfiedBy(control *deb822.Paragraph) (bool, error) {
+	if r.Name == control.Package() {
+		if r.Operator == "" {
+			return true, nil
+		}
+		return version.Satisfies(control.Version(), r.Operator, r.Version)
+	}
+
+	provides, err := ParseRelations(control.Value("Provides"))
+	if err != nil {
+		return false, fmt.Errorf("failed to parse Provides: %w", err)
+	}
+	for _, alternatives := range provides {
+		for _, p := range alternatives {
+			if p.Name != r.Name {
+				continue
+			}
+			if r.Operator == "" {
+				return true, nil
+			}
+			// only versioned provides can satisfy versioned relations
+			if p.Operator != "=" {
+				continue
+			}
+			ok, err := version.Satisfies(p.Version, r.Operator, r.Version)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// SatisfiedBy reports whether the package described by control satisfies any of the alternatives
+func (a Alternatives) SatisfiedBy(control *deb822.Paragraph) (bool, error) {
+	for _, r := range a {
+		ok, err := r.SatisfiedBy(control)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,137 @@
+package deb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/porty/deb-info/deb822"
+)
+
+func TestParseRelations(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  Relations
+		// str is what String gives back, if different from value
+		str string
+	}{
+		{"", nil, ""},
+		{"libc6", Relations{{{Name: "libc6"}}}, ""},
+		{"libc6 (>= 2.34)", Relations{{{Name: "libc6", Operator: ">=", Version: "2.34"}}}, ""},
+		{"libc6(>=2.34)", Relations{{{Name: "libc6", Operator: ">=", Version: "2.34"}}}, "libc6 (>= 2.34)"},
+		{"a (< 1)", Relations{{{Name: "a", Operator: "<=", Version: "1"}}}, "a (<= 1)"},
+		{"a (> 1)", Relations{{{Name: "a", Operator: ">=", Version: "1"}}}, "a (>= 1)"},
+		{"a (<< 1:2.0~rc1-3)", Relations{{{Name: "a", Operator: "<<", Version: "1:2.0~rc1-3"}}}, ""},
+		{
+			"a, b | c (= 1), d",
+			Relations{{{Name: "a"}}, {{Name: "b"}, {Name: "c", Operator: "=", Version: "1"}}, {{Name: "d"}}},
+			"",
+		},
+		{"a,\n b,\n", Relations{{{Name: "a"}}, {{Name: "b"}}}, "a, b"},
+		{"python3:any", Relations{{{Name: "python3", ArchQualifier: "any"}}}, ""},
+		{
+			"libc6:amd64 (>= 2.34) [amd64 !i386]",
+			Relations{{{Name: "libc6", ArchQualifier: "amd64", Operator: ">=", Version: "2.34", Architectures: []string{"amd64", "!i386"}}}},
+			"",
+		},
+		{
+			"foo <!nocheck> <stage1 cross>",
+			Relations{{{Name: "foo", Profiles: [][]string{{"!nocheck"}, {"stage1", "cross"}}}}},
+			"",
+		},
+		{
+			"foo (>= 1) [linux-any] <!nodoc>",
+			Relations{{{Name: "foo", Operator: ">=", Version: "1", Architectures: []string{"linux-any"}, Profiles: [][]string{{"!nodoc"}}}}},
+			"",
+		},
+	} {
+		got, err := ParseRelations(test.value)
+		if err != nil {
+			t.Errorf("ParseRelations(%q): %v", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseRelations(%q) = %s, want %s", test.value, got, test.want)
+		}
+		want := test.str
+		if want == "" {
+			want = test.value
+		}
+		if got.String() != want {
+			t.Errorf("ParseRelations(%q).String() = %q, want %q", test.value, got.String(), want)
+		}
+	}
+}
+
+func TestParseRelationsErrors(t *testing.T) {
+	for _, value := range []string{
+		"a | ",
+		"(>= 1)",
+		"a (>= 1",
+		"a (~ 1)",
+		"a (>=)",
+		"a [amd64",
+		"a <nocheck",
+		"a b",
+		"a (>= 1) c",
+	} {
+		if got, err := ParseRelations(value); err == nil {
+			t.Errorf("ParseRelations(%q) = %s, want an error", value, got)
+		}
+	}
+}
+
+func TestSatisfiedBy(t *testing.T) {
+	control, err := deb822.ParseParagraph("Package: foo\nVersion: 1:2.0-1\nProvides: bar (= 3.0), baz, mail-transport-agent\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		relation string
+		want     bool
+	}{
+		{"foo", true},
+		{"foo (= 1:2.0-1)", true},
+		{"foo (>= 2.0)", true},
+		{"foo (>= 1:2.0)", true},
+		{"foo (>> 1:2.0-1)", false},
+		{"foo (<< 1:2.0-1~)", false},
+		{"foo (<< 2:0)", true},
+		{"foo:any", true},
+		{"other", false},
+		{"bar", true},
+		{"bar (>= 3.0)", true},
+		{"bar (>= 3.1)", false},
+		{"baz", true},
+		// an unversioned provide can't satisfy a versioned relation
+		{"baz (>= 1)", false},
+		{"other | baz", true},
+		{"other | bar (<< 3.0)", false},
+	} {
+		relations, err := ParseRelations(test.relation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := relations[0].SatisfiedBy(control)
+		if err != nil {
+			t.Errorf("%s: %v", test.relation, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s satisfied by foo: got %v, want %v", test.relation, got, test.want)
+		}
+	}
+}
+
+func TestSatisfiedByBadVersion(t *testing.T) {
+	control, err := deb822.ParseParagraph("Package: foo\nVersion: 1.0\nProvides: bar (= !bad)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relations, err := ParseRelations("bar (>= 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relations[0].SatisfiedBy(control); err == nil {
+		t.Error("expected an error for an invalid provided version")
+	}
+}
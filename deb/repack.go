@@ -0,0 +1,149 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/porty/deb-info/ar"
+	"github.com/porty/deb-info/deb822"
+)
+
+// Repack copies the package in r to w with its control file changed by edit. The control
+// archive is regenerated with the same compression, keeping its other members and their
+// metadata, and the data archive and any other members are copied byte for byte.
+func Repack(r io.Reader, w io.Writer, edit func(control *deb822.Paragraph) error) error {
+	if err := ar.ReadSignature(r); err != nil {
+		return fmt.Errorf("failed to read Debian package header: %w", err)
+	}
+	archive, aw := ar.NewReader(r), ar.NewWriter(w)
+
+	debianBinary, err := archive.ReadFile()
+	if err != nil {
+		return fmt.Errorf("failed to read debian-binary: %w", err)
+	}
+	if debianBinary.Name != "debian-binary" {
+		return fmt.Errorf("expected file %q, got %q", "debian-binary", debianBinary.Name)
+	}
+	if err := aw.WriteFile(debianBinary); err != nil {
+		return err
+	}
+
+	controlInfo, err := archive.ReadFile()
+	if err != nil {
+		return fmt.Errorf("failed to read control archive: %w", err)
+	}
+	if controlInfo.Size > maxControlArchiveSize {
+		return fmt.Errorf("control archive too large at %d bytes", controlInfo.Size)
+	}
+	control, err := rewriteControl(controlInfo, edit)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite control archive: %w", err)
+	}
+	controlInfo.Size = int64(len(control))
+	controlInfo.Reader = bytes.NewReader(control)
+	if err := aw.WriteFile(controlInfo); err != nil {
+		return err
+	}
+
+	for {
+		fi, err := archive.ReadFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := aw.WriteFile(fi); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", fi.Name, err)
+		}
+	}
+	return aw.Close()
+}
+
+// rewriteControl returns the control archive member fi with its control file changed by edit
+func rewriteControl(fi *ar.FileInfo, edit func(control *deb822.Paragraph) error) ([]byte, error) {
+	r, compression, err := openTar(fi, "control")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	c, err := findCompressor(compression)
+	if err != nil {
+		return nil, fmt.Errorf("can't write %s compression, only read it", compression)
+	}
+
+	var out bytes.Buffer
+	cw, err := c.create(&out, 0)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(cw)
+
+	foundControl := false
+	var budget controlBudget
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file from control archive: %w", err)
+		}
+		if err := budget.add(hdr); err != nil {
+			return nil, err
+		}
+
+		data, err := budget.read(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Clean(strings.TrimPrefix(hdr.Name, "./")) == "control" {
+			control, err := editControl(string(data), edit)
+			if err != nil {
+				return nil, err
+			}
+			data = []byte(control)
+			hdr.Size = int64(len(data))
+			foundControl = true
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", hdr.Name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", hdr.Name, err)
+		}
+	}
+	if !foundControl {
+		return nil, errors.New("failed to find control file in control archive")
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// editControl applies edit to a control file, checking the result is still usable
+func editControl(text string, edit func(control *deb822.Paragraph) error) (string, error) {
+	control, err := ParseControl(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse control file: %w", err)
+	}
+	if err := edit(control); err != nil {
+		return "", err
+	}
+	if err := checkControl(control); err != nil {
+		return "", err
+	}
+	return control.String(), nil
+}
@@ -0,0 +1,160 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/porty/deb-info/ar"
+	"github.com/porty/deb-info/deb822"
+)
+
+// arMembers returns the names and contents of the members of the ar archive in data
+func arMembers(t *testing.T, data []byte) ([]string, map[string][]byte) {
+	t.Helper()
+	r := bytes.NewReader(data)
+	if err := ar.ReadSignature(r); err != nil {
+		t.Fatal(err)
+	}
+	archive := ar.NewReader(r)
+	var names []string
+	members := map[string][]byte{}
+	for {
+		fi, err := archive.ReadFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := io.ReadAll(fi.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, fi.Name)
+		members[fi.Name] = contents
+	}
+	return names, members
+}
+
+// repack runs Repack on pkg, returning the new package
+func repack(t *testing.T, pkg []byte, edit func(control *deb822.Paragraph) error) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Repack(bytes.NewReader(pkg), &out, edit); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestRepack(t *testing.T) {
+	for _, compression := range []string{"gzip", "xz", "zstd", "none"} {
+		t.Run(compression, func(t *testing.T) {
+			original := buildPackage(t, BuildOptions{Compression: compression, ModTime: time.Unix(1600000000, 0)})
+			repacked := repack(t, original, func(control *deb822.Paragraph) error {
+				control.Set("Version", "1.0-2")
+				control.Set("Section", "misc")
+				control.Del("Maintainer")
+				return nil
+			})
+
+			// everything apart from the control archive is copied as it was
+			names, before := arMembers(t, original)
+			repackedNames, after := arMembers(t, repacked)
+			if !reflect.DeepEqual(repackedNames, names) {
+				t.Fatalf("got members %q, want %q", repackedNames, names)
+			}
+			for _, name := range names {
+				if strings.HasPrefix(name, "control.tar") {
+					continue
+				}
+				if !bytes.Equal(after[name], before[name]) {
+					t.Errorf("member %s changed", name)
+				}
+			}
+
+			oldPkg, err := Open(bytes.NewReader(original))
+			if err != nil {
+				t.Fatal(err)
+			}
+			newPkg, err := Open(bytes.NewReader(repacked))
+			if err != nil {
+				t.Fatal(err)
+			}
+			const want = "Package: built\nVersion: 1.0-2\nArchitecture: all\nDescription: test\nSection: misc\n"
+			if newPkg.Control != want {
+				t.Errorf("got control %q, want %q", newPkg.Control, want)
+			}
+			if newPkg.ControlCompression != oldPkg.ControlCompression {
+				t.Errorf("control compression changed from %s to %s", oldPkg.ControlCompression, newPkg.ControlCompression)
+			}
+			if len(newPkg.ControlMembers) != len(oldPkg.ControlMembers) {
+				t.Fatalf("got %d control members, want %d", len(newPkg.ControlMembers), len(oldPkg.ControlMembers))
+			}
+			for i, m := range newPkg.ControlMembers {
+				old := oldPkg.ControlMembers[i]
+				if m.Name != old.Name || m.Mode != old.Mode {
+					t.Errorf("control member %s %s became %s %s", old.Name, old.Mode, m.Name, m.Mode)
+				}
+				if m.Name != "control" && !bytes.Equal(m.Data, old.Data) {
+					t.Errorf("control member %s changed", m.Name)
+				}
+			}
+			if got, want := dataNames(t, newPkg), dataNames(t, oldPkg); !reflect.DeepEqual(got, want) {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRepackKeepsComments(t *testing.T) {
+	control := makeTar(t, []testEntry{tarFile("./control", "# built by hand\nPackage: test\nVersion: 1.0\nArchitecture: all\n")})
+	original := makePackage(t, control, makeTar(t, []testEntry{tarDir("./")}))
+	repacked := repack(t, original, func(control *deb822.Paragraph) error {
+		control.Set("Package", "renamed")
+		return nil
+	})
+	pkg, err := Open(bytes.NewReader(repacked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "# built by hand\nPackage: renamed\nVersion: 1.0\nArchitecture: all\n"
+	if m := pkg.ControlMember("control"); m == nil || string(m.Data) != want {
+		t.Errorf("got control member %+v, want %q", m, want)
+	}
+}
+
+func TestRepackErrors(t *testing.T) {
+	errEdit := errors.New("edit failed")
+	for _, test := range []struct {
+		name    string
+		edit    func(control *deb822.Paragraph) error
+		message string
+	}{
+		{"no Package", func(c *deb822.Paragraph) error { c.Del("Package"); return nil }, "no Package field"},
+		{"no Architecture", func(c *deb822.Paragraph) error { c.Set("Architecture", ""); return nil }, "no Architecture field"},
+		{"bad Version", func(c *deb822.Paragraph) error { c.Set("Version", "1.0_1"); return nil }, "invalid Version"},
+		{"edit error", func(c *deb822.Paragraph) error { return errEdit }, errEdit.Error()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			original := buildPackage(t, BuildOptions{Compression: "gzip"})
+			err := Repack(bytes.NewReader(original), io.Discard, test.edit)
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected an error containing %q, got %v", test.message, err)
+			}
+		})
+	}
+
+	t.Run("no control file", func(t *testing.T) {
+		control := makeTar(t, []testEntry{tarFile("./postinst", "#!/bin/sh\n")})
+		original := makePackage(t, control, makeTar(t, []testEntry{tarDir("./")}))
+		err := Repack(bytes.NewReader(original), io.Discard, func(*deb822.Paragraph) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "failed to find control file") {
+			t.Errorf("expected a missing control file error, got %v", err)
+		}
+	})
+}
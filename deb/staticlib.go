@@ -0,0 +1,67 @@
+package deb
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/porty/deb-info/ar"
+)
+
+// StaticLibrary is the contents of a static library (.a file)
+type StaticLibrary struct {
+	Objects []*StaticObject `json:"objects"`
+}
+
+// StaticObject is an object file in a static library and the symbols it defines
+type StaticObject struct {
+	Name    string   `json:"name"`
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+func isStaticLibrary(name string) bool {
+	return strings.HasSuffix(name, ".a")
+}
+
+// ReadStaticLibrary lists the objects in a static library and the symbols each defines.
+// It returns nil if data isn't an ar archive.
+func ReadStaticLibrary(data []byte) (*StaticLibrary, error) {
+	if !bytes.HasPrefix(data, []byte(ar.Signature)) {
+		return nil, nil
+	}
+
+	idx, err := ar.OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := idx.Symbols()
+	if err != nil {
+		return nil, err
+	}
+
+	lib := &StaticLibrary{}
+	objects := map[string]*StaticObject{}
+	for _, m := range idx.Members {
+		if ar.IsSymbolTable(m.Name) {
+			continue
+		}
+		if _, exists := objects[m.Name]; exists {
+			continue
+		}
+		obj := &StaticObject{
+			Name: m.Name,
+		}
+		objects[m.Name] = obj
+		lib.Objects = append(lib.Objects, obj)
+	}
+	for sym, member := range symbols {
+		if obj, ok := objects[member]; ok {
+			obj.Symbols = append(obj.Symbols, sym)
+		}
+	}
+	for _, obj := range lib.Objects {
+		sort.Strings(obj.Symbols)
+	}
+
+	return lib, nil
+}
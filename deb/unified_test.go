@@ -0,0 +1,94 @@
+package deb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns "line1\n" to "lineN\n"
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line%d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	// the expected diffs are what diff -u gives
+	for _, test := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{"both empty", "", "", ""},
+		{
+			"add at end", "a\nb\n", "a\nb\nc\n",
+			"--- a/x\n+++ b/x\n@@ -1,2 +1,3 @@\n a\n b\n+c\n",
+		},
+		{
+			"remove first", "a\nb\nc\n", "b\nc\n",
+			"--- a/x\n+++ b/x\n@@ -1,3 +1,2 @@\n-a\n b\n c\n",
+		},
+		{
+			"change middle", numberedLines(9), strings.Replace(numberedLines(9), "line5\n", "five\n", 1),
+			"--- a/x\n+++ b/x\n@@ -2,7 +2,7 @@\n line2\n line3\n line4\n-line5\n+five\n line6\n line7\n line8\n",
+		},
+		{
+			"separate hunks", numberedLines(20), strings.NewReplacer("line2\n", "two\n", "line18\n", "eighteen\n").Replace(numberedLines(20)),
+			"--- a/x\n+++ b/x\n@@ -1,5 +1,5 @@\n line1\n-line2\n+two\n line3\n line4\n line5\n" +
+				"@@ -15,6 +15,6 @@\n line15\n line16\n line17\n-line18\n+eighteen\n line19\n line20\n",
+		},
+		{
+			"merged hunks", numberedLines(12), strings.NewReplacer("line3\n", "three\n", "line9\n", "nine\n").Replace(numberedLines(12)),
+			"--- a/x\n+++ b/x\n@@ -1,12 +1,12 @@\n line1\n line2\n-line3\n+three\n line4\n line5\n line6\n line7\n line8\n-line9\n+nine\n line10\n line11\n line12\n",
+		},
+		{
+			"empty to text", "", "a\nb\n",
+			"--- a/x\n+++ b/x\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"text to empty", "a\nb\n", "",
+			"--- a/x\n+++ b/x\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			"no newline at end", "a\nb", "a\nc",
+			"--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			"newline added at end", "a\nb", "a\nb\n",
+			"--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := unifiedDiff("a/x", "b/x", test.a, test.b); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooBig(t *testing.T) {
+	// past maxDiffCells the old lines are all removed and the new ones added, even if some match
+	n := 2100
+	a := splitLines(numberedLines(n))
+	b := splitLines(strings.Replace(numberedLines(n), "line1\n", "one\n", 1))
+	if len(a)*len(b) <= maxDiffCells {
+		t.Fatalf("%d lines aren't enough to exceed maxDiffCells", n)
+	}
+	edits := diffLines(a, b)
+	if len(edits) != 2*n {
+		t.Fatalf("got %d edits, want %d", len(edits), 2*n)
+	}
+	for i, e := range edits {
+		want := byte('-')
+		if i >= n {
+			want = '+'
+		}
+		if e.op != want {
+			t.Fatalf("edit %d is %c, want %c", i, e.op, want)
+		}
+	}
+}
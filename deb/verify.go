@@ -0,0 +1,158 @@
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ParseMD5Sums parses an md5sums control member into a map of path (without a leading "/" or "./") to hex digest
+func ParseMD5Sums(data []byte) (map[string]string, error) {
+	result := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok || len(sum) != 2*md5.Size {
+			return nil, fmt.Errorf("md5sums line %d: invalid line %q", lineNumber, line)
+		}
+		if _, err := hex.DecodeString(sum); err != nil {
+			return nil, fmt.Errorf("md5sums line %d: invalid digest %q", lineNumber, sum)
+		}
+		// md5sum separates with two spaces, or a space and "*" for binary mode
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		result[NormalizePath(name)] = strings.ToLower(sum)
+	}
+	return result, scanner.Err()
+}
+
+// NormalizePath turns data archive, md5sums and user-supplied paths into the same form, e.g. "usr/bin/foo"
+func NormalizePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// Mismatch is a file whose contents don't match md5sums
+type Mismatch struct {
+	Path     string `json:"path"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// VerifyResult is the outcome of Verify
+type VerifyResult struct {
+	// Checked is the number of files hashed
+	Checked int `json:"checked"`
+	// Missing are listed in md5sums but not in the data archive
+	Missing []string `json:"missing,omitempty"`
+	// Extra are regular files in the data archive not listed in md5sums, ignoring conffiles
+	Extra      []string    `json:"extra,omitempty"`
+	Mismatched []*Mismatch `json:"mismatched,omitempty"`
+}
+
+// OK reports whether no problems were found
+func (r *VerifyResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// Verify reads the data archive, hashing each regular file and comparing it against
+// the md5sums control member. It consumes the data archive, so can't be used with Data.
+func (p *Package) Verify() (*VerifyResult, error) {
+	md5sumsMember := p.ControlMember("md5sums")
+	if md5sumsMember == nil {
+		return nil, errors.New("package has no md5sums control member")
+	}
+	expected, err := ParseMD5Sums(md5sumsMember.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// dh_md5sums leaves out conffiles by default
+	conffiles := map[string]bool{}
+	if m := p.ControlMember("conffiles"); m != nil {
+		for _, line := range strings.Split(string(m.Data), "\n") {
+			if name := strings.TrimSpace(line); name != "" {
+				conffiles[NormalizePath(name)] = true
+			}
+		}
+	}
+
+	data, err := p.Data()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	result := &VerifyResult{}
+	seen := map[string]bool{}
+	actual := map[string]string{}
+
+	check := func(name string, sum string) {
+		seen[name] = true
+		want, ok := expected[name]
+		if !ok {
+			if !conffiles[name] {
+				result.Extra = append(result.Extra, name)
+			}
+			return
+		}
+		result.Checked++
+		if want != sum {
+			result.Mismatched = append(result.Mismatched, &Mismatch{
+				Path:     name,
+				Expected: want,
+				Actual:   sum,
+			})
+		}
+	}
+
+	for {
+		hdr, err := data.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := NormalizePath(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			h := md5.New()
+			if _, err := io.Copy(h, data); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+			}
+			sum := hex.EncodeToString(h.Sum(nil))
+			actual[name] = sum
+			check(name, sum)
+		case tar.TypeLink:
+			// hard links have no contents of their own, but are listed in md5sums as files
+			sum, ok := actual[NormalizePath(hdr.Linkname)]
+			if !ok {
+				return nil, fmt.Errorf("hard link %s points to unknown file %s", hdr.Name, hdr.Linkname)
+			}
+			check(name, sum)
+		}
+	}
+
+	for name := range expected {
+		if !seen[name] {
+			result.Missing = append(result.Missing, name)
+		}
+	}
+	sort.Strings(result.Missing)
+
+	return result, nil
+}
@@ -0,0 +1,305 @@
+// Package deb822 parses the RFC 822 style control data used by Debian control files,
+// Packages and Sources indices, .dsc and .changes files and the dpkg status file
+package deb822
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Field is a single field of a paragraph
+type Field struct {
+	// Name is the field name as written, field names are matched case-insensitively
+	Name string
+	// Value is the field value with leading and trailing space removed. Continuation lines
+	// are kept, joined with "\n" and with their leading space removed.
+	// Change it with Paragraph.Set so the field is reformatted when written.
+	Value string
+
+	// comments are the comment lines before the field, kept when the field is changed
+	comments string
+	// raw is the original text of the field, so unmodified fields are written back
+	// exactly as they were read
+	raw string
+}
+
+// Paragraph is a block of fields, separated from other paragraphs by blank lines
+type Paragraph struct {
+	Fields []*Field
+
+	// trailer holds comments after the last field
+	trailer string
+}
+
+// Parse reads all paragraphs from r. Lines starting with "#" are comments, and
+// an OpenPGP clearsigned wrapper (as used by .dsc and .changes files) is skipped.
+func Parse(r io.Reader) ([]*Paragraph, error) {
+	var paragraphs []*Paragraph
+	var current *Paragraph
+	var field *Field
+	var comments strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	lineNumber := 0
+	inSignedHeader := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNumber++
+
+		if lineNumber == 1 && line == "-----BEGIN PGP SIGNED MESSAGE-----" {
+			inSignedHeader = true
+			continue
+		}
+		if inSignedHeader {
+			// armor headers such as "Hash: SHA256" run until a blank line
+			if strings.TrimSpace(line) == "" {
+				inSignedHeader = false
+			}
+			continue
+		}
+		if line == "-----BEGIN PGP SIGNATURE-----" {
+			break
+		}
+
+		if strings.TrimSpace(line) == "" {
+			// end of paragraph
+			if current != nil {
+				current.trailer = comments.String()
+				comments.Reset()
+			}
+			current = nil
+			field = nil
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			comments.WriteString(line + "\n")
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if field == nil {
+				return nil, fmt.Errorf("line %d: continuation line without a field", lineNumber)
+			}
+			field.Value += "\n" + strings.TrimRight(line[1:], " \t")
+			field.raw += comments.String() + line + "\n"
+			comments.Reset()
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: invalid line %q", lineNumber, line)
+		}
+		name := parts[0]
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: invalid field name %q", lineNumber, name)
+		}
+
+		if current == nil {
+			current = &Paragraph{}
+			paragraphs = append(paragraphs, current)
+		}
+		if _, exists := current.Get(name); exists {
+			return nil, fmt.Errorf("line %d: duplicate field %q", lineNumber, name)
+		}
+		field = &Field{
+			Name:     name,
+			Value:    strings.TrimSpace(parts[1]),
+			comments: comments.String(),
+			raw:      line + "\n",
+		}
+		comments.Reset()
+		current.Fields = append(current.Fields, field)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		current.trailer = comments.String()
+	}
+
+	return paragraphs, nil
+}
+
+// ParseParagraph parses data that must hold exactly one paragraph, such as a binary package control file
+func ParseParagraph(data string) (*Paragraph, error) {
+	paragraphs, err := Parse(strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	switch len(paragraphs) {
+	case 0:
+		return nil, errors.New("no fields found")
+	case 1:
+		return paragraphs[0], nil
+	default:
+		return nil, fmt.Errorf("expected 1 paragraph, found %d", len(paragraphs))
+	}
+}
+
+// Field returns the named field, matching the name case-insensitively
+func (p *Paragraph) Field(name string) *Field {
+	for _, f := range p.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the named field
+func (p *Paragraph) Get(name string) (string, bool) {
+	if f := p.Field(name); f != nil {
+		return f.Value, true
+	}
+	return "", false
+}
+
+// Value returns the value of the named field, or "" if there isn't one
+func (p *Paragraph) Value(name string) string {
+	v, _ := p.Get(name)
+	return v
+}
+
+// Set replaces the value of the named field, keeping any comments before it, or adds it
+// to the end of the paragraph
+func (p *Paragraph) Set(name string, value string) {
+	if f := p.Field(name); f != nil {
+		f.Value = value
+		f.raw = ""
+		return
+	}
+	p.Fields = append(p.Fields, &Field{
+		Name:  name,
+		Value: value,
+	})
+}
+
+// Del removes the named field, reporting whether it was present
+func (p *Paragraph) Del(name string) bool {
+	for i, f := range p.Fields {
+		if strings.EqualFold(f.Name, name) {
+			p.Fields = append(p.Fields[:i], p.Fields[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the paragraph, keeping the original text of fields that haven't been changed
+func (p *Paragraph) String() string {
+	var b strings.Builder
+	for _, f := range p.Fields {
+		b.WriteString(f.String())
+	}
+	b.WriteString(p.trailer)
+	return b.String()
+}
+
+// String formats the field, keeping its original text if it hasn't been changed
+func (f *Field) String() string {
+	if f.raw != "" {
+		return f.comments + f.raw
+	}
+	var b strings.Builder
+	b.WriteString(f.comments)
+	for i, line := range strings.Split(f.Value, "\n") {
+		if i == 0 {
+			b.WriteString(f.Name + ":")
+			if line != "" {
+				b.WriteString(" " + line)
+			}
+		} else {
+			if line == "" {
+				// blank lines would end the paragraph
+				line = "."
+			}
+			b.WriteString("\n " + line)
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// MarshalJSON encodes the paragraph as an object of field name to value, in field order
+func (p *Paragraph) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range p.Fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Package returns the Package field
+func (p *Paragraph) Package() string {
+	return p.Value("Package")
+}
+
+// Version returns the Version field
+func (p *Paragraph) Version() string {
+	return p.Value("Version")
+}
+
+// Architecture returns the Architecture field
+func (p *Paragraph) Architecture() string {
+	return p.Value("Architecture")
+}
+
+// InstalledSize returns the Installed-Size field, in KiB. ok is false if the field is missing.
+func (p *Paragraph) InstalledSize() (size int64, ok bool, err error) {
+	v, ok := p.Get("Installed-Size")
+	if !ok {
+		return 0, false, nil
+	}
+	size, err = strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, true, fmt.Errorf("invalid Installed-Size %q: %w", v, err)
+	}
+	return size, true, nil
+}
+
+// Synopsis returns the first line of the Description field
+func (p *Paragraph) Synopsis() string {
+	synopsis, _, _ := strings.Cut(p.Value("Description"), "\n")
+	return synopsis
+}
+
+// ExtendedDescription returns the lines of the Description field after the synopsis,
+// with "." lines turned back into blank lines
+func (p *Paragraph) ExtendedDescription() string {
+	_, extended, _ := strings.Cut(p.Value("Description"), "\n")
+	if extended == "" {
+		return ""
+	}
+	lines := strings.Split(extended, "\n")
+	for i, line := range lines {
+		if line == "." {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
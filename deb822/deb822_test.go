@@ -0,0 +1,278 @@
+package deb822
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"Package: foo\nVersion: 1.0\n",
+		"Package:foo\nVersion:   1.0  \n",
+		"package: foo\nDescription: short\n long line\n .\n  indented\n",
+		"# leading comment\nPackage: foo\n# between\nVersion: 1.0\n# trailing\n",
+		"Package: foo\nDepends: a,\n# inside a continuation\n b\n",
+		"Empty:\nPackage: foo\n",
+		"Tab: x\n\tcontinued\n",
+	} {
+		p, err := ParseParagraph(text)
+		if err != nil {
+			t.Errorf("ParseParagraph(%q): %v", text, err)
+			continue
+		}
+		if got := p.String(); got != text {
+			t.Errorf("round trip of %q gave %q", text, got)
+		}
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	p, err := ParseParagraph("Package:  foo \nDescription: short\n first  \n .\n  indented\nEmpty:\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"Package", "foo", true},
+		{"package", "foo", true},
+		{"PACKAGE", "foo", true},
+		{"Description", "short\nfirst\n.\n indented", true},
+		{"Empty", "", true},
+		{"Missing", "", false},
+	} {
+		if value, ok := p.Get(test.name); value != test.value || ok != test.ok {
+			t.Errorf("Get(%q) = %q, %v, want %q, %v", test.name, value, ok, test.value, test.ok)
+		}
+	}
+	if p.Synopsis() != "short" {
+		t.Errorf("Synopsis() = %q", p.Synopsis())
+	}
+	if p.ExtendedDescription() != "first\n\n indented" {
+		t.Errorf("ExtendedDescription() = %q", p.ExtendedDescription())
+	}
+}
+
+func TestParseParagraphs(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		text     string
+		packages []string
+	}{
+		{"single", "Package: a\n", []string{"a"}},
+		{"several", "Package: a\n\nPackage: b\n\n\n\nPackage: c\n", []string{"a", "b", "c"}},
+		{"blank lines with spaces", "Package: a\n  \t\nPackage: b\n", []string{"a", "b"}},
+		{"leading and trailing blank lines", "\n\nPackage: a\n\n", []string{"a"}},
+		{"only comments", "# nothing\n", nil},
+		{"empty", "", nil},
+		{
+			"clearsigned",
+			"-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\nSource: a\n\nSource: b\n-----BEGIN PGP SIGNATURE-----\n\nabcdef\n-----END PGP SIGNATURE-----\n",
+			[]string{"a", "b"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			paragraphs, err := Parse(strings.NewReader(test.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var packages []string
+			for _, p := range paragraphs {
+				name, ok := p.Get("Package")
+				if !ok {
+					name = p.Value("Source")
+				}
+				packages = append(packages, name)
+			}
+			if strings.Join(packages, " ") != strings.Join(test.packages, " ") {
+				t.Errorf("got paragraphs %q, want %q", packages, test.packages)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		text string
+	}{
+		{"continuation first", " continued\n"},
+		{"continuation after blank line", "Package: a\n\n continued\n"},
+		{"no colon", "Package a\n"},
+		{"empty name", ": a\n"},
+		{"space in name", "Pack age: a\n"},
+		{"duplicate", "Package: a\nPackage: b\n"},
+		{"duplicate in another case", "Package: a\npackage: b\n"},
+	} {
+		if _, err := Parse(strings.NewReader(test.text)); err == nil {
+			t.Errorf("%s: Parse(%q) succeeded", test.name, test.text)
+		}
+	}
+
+	for _, text := range []string{"", "# only a comment\n", "Package: a\n\nPackage: b\n"} {
+		if _, err := ParseParagraph(text); err == nil {
+			t.Errorf("ParseParagraph(%q) succeeded", text)
+		}
+	}
+}
+
+func TestSetDel(t *testing.T) {
+	const control = "Package: foo\nVersion: 1.0\nDescription: short\n long\n"
+	for _, test := range []struct {
+		name string
+		edit func(p *Paragraph)
+		want string
+	}{
+		{
+			"set in place",
+			func(p *Paragraph) { p.Set("Version", "2.0") },
+			"Package: foo\nVersion: 2.0\nDescription: short\n long\n",
+		},
+		{
+			"set keeps the name as written",
+			func(p *Paragraph) { p.Set("VERSION", "2.0") },
+			"Package: foo\nVersion: 2.0\nDescription: short\n long\n",
+		},
+		{
+			"set adds to the end",
+			func(p *Paragraph) { p.Set("Depends", "libc6") },
+			control + "Depends: libc6\n",
+		},
+		{
+			"set multi-line",
+			func(p *Paragraph) { p.Set("Description", "new\nfirst\n\nsecond") },
+			"Package: foo\nVersion: 1.0\nDescription: new\n first\n .\n second\n",
+		},
+		{
+			"set empty",
+			func(p *Paragraph) { p.Set("Version", "") },
+			"Package: foo\nVersion:\nDescription: short\n long\n",
+		},
+		{
+			"del",
+			func(p *Paragraph) {
+				if !p.Del("version") {
+					t.Error("Del(version) reported no field")
+				}
+			},
+			"Package: foo\nDescription: short\n long\n",
+		},
+		{
+			"del missing",
+			func(p *Paragraph) {
+				if p.Del("Depends") {
+					t.Error("Del(Depends) reported a field")
+				}
+			},
+			control,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ParseParagraph(control)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.edit(p)
+			got := p.String()
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			// the result must parse back to the same fields
+			again, err := ParseParagraph(got)
+			if err != nil {
+				t.Fatalf("result doesn't parse: %v", err)
+			}
+			if again.String() != got {
+				t.Errorf("result changed when parsed again: %q", again.String())
+			}
+		})
+	}
+}
+
+func TestSetKeepsComments(t *testing.T) {
+	const control = "# about the package\nPackage: foo\n# bump for each release\nVersion: 1.0\nDepends: a,\n# needed for b\n b\n# trailing\n"
+	for _, test := range []struct {
+		name string
+		edit func(p *Paragraph)
+		want string
+	}{
+		{
+			"first field",
+			func(p *Paragraph) { p.Set("Package", "bar") },
+			"# about the package\nPackage: bar\n# bump for each release\nVersion: 1.0\nDepends: a,\n# needed for b\n b\n# trailing\n",
+		},
+		{
+			"field after a comment",
+			func(p *Paragraph) { p.Set("Version", "2.0") },
+			"# about the package\nPackage: foo\n# bump for each release\nVersion: 2.0\nDepends: a,\n# needed for b\n b\n# trailing\n",
+		},
+		{
+			"added field goes before the trailing comments",
+			func(p *Paragraph) { p.Set("Section", "misc") },
+			"# about the package\nPackage: foo\n# bump for each release\nVersion: 1.0\nDepends: a,\n# needed for b\n b\nSection: misc\n# trailing\n",
+		},
+		{
+			// comments inside a field are part of its value's text, so go when it is rewritten
+			"multi-line field",
+			func(p *Paragraph) { p.Set("Depends", "a, c") },
+			"# about the package\nPackage: foo\n# bump for each release\nVersion: 1.0\nDepends: a, c\n# trailing\n",
+		},
+		{
+			"del takes the field's comments",
+			func(p *Paragraph) { p.Del("Version") },
+			"# about the package\nPackage: foo\nDepends: a,\n# needed for b\n b\n# trailing\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ParseParagraph(control)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.edit(p)
+			if got := p.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInstalledSize(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		size int64
+		ok   bool
+		err  bool
+	}{
+		{"Package: a\nInstalled-Size: 1533\n", 1533, true, false},
+		{"Package: a\n", 0, false, false},
+		{"Package: a\nInstalled-Size: big\n", 0, true, true},
+	} {
+		p, err := ParseParagraph(test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, ok, err := p.InstalledSize()
+		if size != test.size || ok != test.ok || (err != nil) != test.err {
+			t.Errorf("InstalledSize of %q = %d, %v, %v", test.text, size, ok, err)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	p, err := ParseParagraph("Package: foo\nVersion: 1.0\nDescription: short\n long\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fields keep their order, unlike a map
+	const want = `{"Package":"foo","Version":"1.0","Description":"short\nlong"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/porty/deb-info/deb"
+)
+
+// diffCommand compares two packages, exiting 1 if they differ like diff(1)
+func diffCommand(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	markdown := fs.Bool("markdown", false, "Output as Markdown, e.g. for a pull request")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info diff [-json | -markdown] OLD NEW")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	oldPkg, closeOld, err := openPackage(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	defer closeOld()
+	newPkg, closeNew, err := openPackage(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(1), err)
+	}
+	defer closeNew()
+
+	oldControl, err := oldPkg.ControlFields()
+	if err != nil {
+		return err
+	}
+	newControl, err := newPkg.ControlFields()
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("%s %s -> %s %s", oldControl.Package(), oldControl.Version(), newControl.Package(), newControl.Version())
+
+	d, err := deb.Diff(oldPkg, newPkg)
+	if err != nil {
+		return fmt.Errorf("failed to compare packages: %w", err)
+	}
+
+	switch {
+	case *jsonOutput:
+		_ = json.NewEncoder(os.Stdout).Encode(d)
+	case *markdown:
+		printDiffMarkdown(os.Stdout, title, d)
+	default:
+		printDiffText(os.Stdout, title, d)
+	}
+
+	if !d.Empty() {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+var changeSigns = map[string]string{
+	deb.ChangeAdded:   "+",
+	deb.ChangeRemoved: "-",
+	deb.ChangeChanged: "~",
+}
+
+func printDiffText(w io.Writer, title string, d *deb.PackageDiff) {
+	fmt.Fprintln(w, title)
+	if d.Empty() {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+
+	if len(d.Fields) > 0 {
+		fmt.Fprintln(w, "\nControl fields:")
+		for _, f := range d.Fields {
+			if !strings.Contains(f.Old, "\n") && !strings.Contains(f.New, "\n") {
+				switch f.Change {
+				case deb.ChangeAdded:
+					fmt.Fprintf(w, "  + %s: %s\n", f.Field, f.New)
+				case deb.ChangeRemoved:
+					fmt.Fprintf(w, "  - %s: %s\n", f.Field, f.Old)
+				default:
+					fmt.Fprintf(w, "  ~ %s: %s -> %s\n", f.Field, f.Old, f.New)
+				}
+				continue
+			}
+			fmt.Fprintf(w, "  %s %s:\n", changeSigns[f.Change], f.Field)
+			for _, line := range splitValue(f.Old) {
+				fmt.Fprintf(w, "    - %s\n", line)
+			}
+			for _, line := range splitValue(f.New) {
+				fmt.Fprintf(w, "    + %s\n", line)
+			}
+		}
+	}
+
+	for _, r := range d.Relations {
+		fmt.Fprintf(w, "\n%s:\n", r.Field)
+		for _, s := range r.Added {
+			fmt.Fprintf(w, "  + %s\n", s)
+		}
+		for _, s := range r.Removed {
+			fmt.Fprintf(w, "  - %s\n", s)
+		}
+		for _, c := range r.Changed {
+			fmt.Fprintf(w, "  ~ %s -> %s\n", c.Old, c.New)
+		}
+	}
+
+	if len(d.Members) > 0 {
+		fmt.Fprintln(w, "\nControl members:")
+		for _, m := range d.Members {
+			fmt.Fprintf(w, "  %s %s", changeSigns[m.Change], m.Name)
+			if m.Mode != "" {
+				fmt.Fprintf(w, " (mode %s)", m.Mode)
+			}
+			fmt.Fprintln(w)
+		}
+		for _, m := range d.Members {
+			if m.Diff != "" {
+				fmt.Fprintf(w, "\n%s", m.Diff)
+			}
+		}
+	}
+
+	if len(d.Files) > 0 {
+		fmt.Fprintln(w, "\nFiles:")
+		for _, f := range d.Files {
+			if len(f.Details) == 0 {
+				fmt.Fprintf(w, "  %s /%s\n", changeSigns[f.Change], f.Path)
+				continue
+			}
+			fmt.Fprintf(w, "  %s /%s: %s\n", changeSigns[f.Change], f.Path, strings.Join(f.Details, ", "))
+		}
+	}
+}
+
+// splitValue splits a multi-line field value into lines, with none for an empty value
+func splitValue(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
+
+func printDiffMarkdown(w io.Writer, title string, d *deb.PackageDiff) {
+	fmt.Fprintf(w, "## %s\n\n", markdownEscape(title))
+	if d.Empty() {
+		fmt.Fprintln(w, "No differences.")
+		return
+	}
+
+	if len(d.Fields) > 0 {
+		fmt.Fprintln(w, "### Control fields")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Field | Old | New |")
+		fmt.Fprintln(w, "| --- | --- | --- |")
+		for _, f := range d.Fields {
+			fmt.Fprintf(w, "| %s | %s | %s |\n", markdownEscape(f.Field), markdownCell(f.Old), markdownCell(f.New))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(d.Relations) > 0 {
+		fmt.Fprintln(w, "### Relationships")
+		fmt.Fprintln(w)
+		for _, r := range d.Relations {
+			fmt.Fprintf(w, "**%s**\n\n", markdownEscape(r.Field))
+			for _, s := range r.Added {
+				fmt.Fprintf(w, "- Added %s\n", markdownCode(s))
+			}
+			for _, s := range r.Removed {
+				fmt.Fprintf(w, "- Removed %s\n", markdownCode(s))
+			}
+			for _, c := range r.Changed {
+				fmt.Fprintf(w, "- Changed %s to %s\n", markdownCode(c.Old), markdownCode(c.New))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	if len(d.Members) > 0 {
+		fmt.Fprintln(w, "### Control members")
+		fmt.Fprintln(w)
+		for _, m := range d.Members {
+			fmt.Fprintf(w, "#### %s (%s)\n\n", markdownEscape(m.Name), m.Change)
+			if m.Mode != "" {
+				fmt.Fprintf(w, "Mode %s\n\n", markdownCode(m.Mode))
+			}
+			if m.Diff != "" {
+				fmt.Fprintf(w, "%s\n", markdownCodeBlock("diff", m.Diff))
+			}
+		}
+	}
+
+	if len(d.Files) > 0 {
+		fmt.Fprintln(w, "### Files")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Path | Change | Details |")
+		fmt.Fprintln(w, "| --- | --- | --- |")
+		for _, f := range d.Files {
+			// pipes end table cells even inside code
+			path := strings.ReplaceAll(markdownCode("/"+f.Path), "|", "\\|")
+			fmt.Fprintf(w, "| %s | %s | %s |\n", path, f.Change, markdownCell(strings.Join(f.Details, ", ")))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// markdownEscape escapes characters that would otherwise be formatting
+func markdownEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<|", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// markdownCode formats s as inline code
+func markdownCode(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	return fence + s + fence
+}
+
+// markdownCodeBlock formats s, which ends with a newline, as a fenced code block. The fence
+// is longer than any run of backticks in s, so nothing in it can end the block early.
+func markdownCodeBlock(lang string, s string) string {
+	fence := "```"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + s + fence + "\n"
+}
+
+// markdownCell formats a possibly multi-line value for a table cell
+func markdownCell(s string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = markdownEscape(line)
+	}
+	return strings.Join(lines, "<br>")
+}
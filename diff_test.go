@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/porty/deb-info/deb"
+)
+
+func TestPrintDiffMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		diff *deb.PackageDiff
+		// want are lines expected in the output
+		want []string
+	}{
+		{
+			"field name is escaped",
+			&deb.PackageDiff{Fields: []*deb.FieldChange{{Field: "X-Odd|Name_*", Change: deb.ChangeAdded, New: "a|b"}}},
+			[]string{`| X-Odd\|Name\_\* |  | a\|b |`},
+		},
+		{
+			"relation field is escaped",
+			&deb.PackageDiff{Relations: []*deb.RelationChange{{Field: "X-*Depends*", Added: []string{"foo"}}}},
+			[]string{`**X-\*Depends\***`, "- Added `foo`"},
+		},
+		{
+			"member diff fence",
+			&deb.PackageDiff{Members: []*deb.MemberChange{{Name: "postinst", Change: deb.ChangeChanged, Diff: "-old\n+new\n"}}},
+			[]string{"```diff", "-old", "+new", "```"},
+		},
+		{
+			"member diff with backticks",
+			&deb.PackageDiff{Members: []*deb.MemberChange{{Name: "postinst", Change: deb.ChangeChanged, Diff: "-x=`date`\n+cat <<EOF\n+```\n+``````\n"}}},
+			[]string{"```````diff", "+```", "+``````", "```````"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printDiffMarkdown(&buf, "a -> b", test.diff)
+			lines := strings.Split(buf.String(), "\n")
+			next := 0
+			for _, line := range lines {
+				if next < len(test.want) && line == test.want[next] {
+					next++
+				}
+			}
+			if next < len(test.want) {
+				t.Errorf("missing line %q in:\n%s", test.want[next], buf.String())
+			}
+		})
+	}
+}
+
+func TestMarkdownCodeBlock(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{"a\n", "```diff\na\n```\n"},
+		{"`a`\n", "```diff\n`a`\n```\n"},
+		{"```\n", "````diff\n```\n````\n"},
+		{"a ```` b\n", "`````diff\na ```` b\n`````\n"},
+	} {
+		if got := markdownCodeBlock("diff", test.s); got != test.want {
+			t.Errorf("markdownCodeBlock(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
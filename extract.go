@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/porty/deb-info/deb"
+)
+
+// extractCommand unpacks the data archive, and optionally the control archive, to directories
+func extractCommand(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	controlDir := fs.String("control", "", "Also extract the control archive to this directory")
+	mtimes := fs.Bool("mtime", false, "Set modification times from the package")
+	maxSize := fs.Int64("max-size", deb.DefaultMaxExtractSize, "Give up after writing this many bytes")
+	maxRatio := fs.Int64("max-ratio", deb.DefaultMaxExtractRatio, "Give up after writing this many times the compressed data archive size, -1 for no limit")
+	maxEntries := fs.Int("max-entries", deb.DefaultMaxExtractEntries, "Give up after this many data archive entries")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info extract [-control DIR] [-mtime] PACKAGE [DIR]")
+		fmt.Fprintln(fs.Output(), "PACKAGE can be a file, an HTTP(S) URL, or - for standard input.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 || (fs.NArg() == 1 && *controlDir == "") {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	pkg, closePkg, err := openPackage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closePkg()
+
+	if *controlDir != "" {
+		if err := pkg.ExtractControl(*controlDir); err != nil {
+			return fmt.Errorf("failed to extract control archive: %w", err)
+		}
+		fmt.Printf("Extracted %d control archive members to %s\n", len(pkg.ControlMembers), *controlDir)
+	}
+
+	dir := fs.Arg(1)
+	if dir == "" {
+		return nil
+	}
+	result, err := pkg.Extract(dir, deb.ExtractOptions{
+		MaxSize:        *maxSize,
+		MaxRatio:       *maxRatio,
+		MaxEntries:     *maxEntries,
+		PreserveMtimes: *mtimes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extract data archive: %w", err)
+	}
+
+	for _, name := range result.Skipped {
+		fmt.Fprintf(os.Stderr, "Skipped %s: only files, directories and links are extracted\n", name)
+	}
+	fmt.Printf("Extracted %d files, %d directories, %d symlinks and %d hard links (%d bytes) to %s\n",
+		result.Files, result.Dirs, result.Symlinks, result.HardLinks, result.Bytes, dir)
+	return nil
+}
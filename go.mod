@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/gabriel-vasile/mimetype v1.4.0
+	github.com/klauspost/compress v1.17.0
 	github.com/ulikunitz/xz v0.5.11
 )
 
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/porty/deb-info/deb"
+	"github.com/porty/deb-info/lint"
+)
+
+// lintCommand runs the lint rules over a package, exiting 1 if anything at or above -fail-on is found
+func lintCommand(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	failOn := fs.String("fail-on", "error", "Exit 1 for findings of at least this severity: info, warning or error")
+	listRules := fs.Bool("list-rules", false, "List the lint rules and exit")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info lint [flags] [PACKAGE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *listRules {
+		w := tabwriter.NewWriter(os.Stdout, 10, 2, 4, ' ', 0)
+		w.Write([]byte("Rule\tSeverity\tDescription\n"))
+		for _, rule := range lint.Rules() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", rule.ID, rule.Severity, rule.Description)
+		}
+		return w.Flush()
+	}
+
+	threshold, err := lint.ParseSeverity(*failOn)
+	if err != nil {
+		return err
+	}
+
+	pkg, closePkg, err := openPackage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closePkg()
+	control, err := pkg.ControlFields()
+	if err != nil {
+		return err
+	}
+
+	linter := lint.New(control, lint.Rules())
+	if err := readData(pkg, deb.DescribeOptions{}, lintVisitor(linter)); err != nil {
+		return fmt.Errorf("failed to read data file: %w", err)
+	}
+	findings := linter.Finish()
+
+	if *jsonOutput {
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		_ = json.NewEncoder(os.Stdout).Encode(findings)
+	} else {
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		fmt.Printf("%d findings\n", len(findings))
+	}
+
+	for _, f := range findings {
+		if f.Severity >= threshold {
+			return exitStatus(1)
+		}
+	}
+	return nil
+}
+
+// lintVisitor passes each data archive entry read by readData to linter
+func lintVisitor(linter *lint.Linter) dataVisitor {
+	return func(hdr *tar.Header, fi *deb.FileInfo, head []byte) {
+		linter.Entry(hdr, head)
+	}
+}
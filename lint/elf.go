@@ -0,0 +1,80 @@
+package lint
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+)
+
+// elfHeader is the part of an ELF file rules care about, parsed from the start of the file
+type elfHeader struct {
+	Class   elf.Class
+	Data    elf.Data
+	Type    elf.Type
+	Machine elf.Machine
+	// Interpreter is whether there is a PT_INTERP program header, which marks
+	// dynamically linked executables (as opposed to shared libraries)
+	Interpreter bool
+}
+
+// parseELFHeader parses the ELF and program headers from head, returning nil if it isn't an ELF file.
+// Program headers past the end of head are ignored.
+func parseELFHeader(head []byte) *elfHeader {
+	if len(head) < 52 || !bytes.HasPrefix(head, []byte(elf.ELFMAG)) {
+		return nil
+	}
+
+	h := &elfHeader{
+		Class: elf.Class(head[elf.EI_CLASS]),
+		Data:  elf.Data(head[elf.EI_DATA]),
+	}
+
+	var order binary.ByteOrder
+	switch h.Data {
+	case elf.ELFDATA2LSB:
+		order = binary.LittleEndian
+	case elf.ELFDATA2MSB:
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	h.Type = elf.Type(order.Uint16(head[16:]))
+	h.Machine = elf.Machine(order.Uint16(head[18:]))
+
+	var phoff uint64
+	var phentsize, phnum uint16
+	switch h.Class {
+	case elf.ELFCLASS32:
+		phoff = uint64(order.Uint32(head[28:]))
+		phentsize = order.Uint16(head[42:])
+		phnum = order.Uint16(head[44:])
+	case elf.ELFCLASS64:
+		if len(head) < 64 {
+			return nil
+		}
+		phoff = order.Uint64(head[32:])
+		phentsize = order.Uint16(head[54:])
+		phnum = order.Uint16(head[56:])
+	default:
+		return nil
+	}
+
+	for i := uint64(0); i < uint64(phnum); i++ {
+		offset := phoff + i*uint64(phentsize)
+		if offset+4 > uint64(len(head)) {
+			break
+		}
+		if elf.ProgType(order.Uint32(head[offset:])) == elf.PT_INTERP {
+			h.Interpreter = true
+			break
+		}
+	}
+
+	return h
+}
+
+// IsExecutable reports whether the file is a program rather than a library or object
+func (h *elfHeader) IsExecutable() bool {
+	return h.Type == elf.ET_EXEC || (h.Type == elf.ET_DYN && h.Interpreter)
+}
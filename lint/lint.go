@@ -0,0 +1,166 @@
+// Package lint checks the contents of Debian packages for common packaging mistakes
+package lint
+
+import (
+	"archive/tar"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/porty/deb-info/deb822"
+)
+
+// HeadSize is how much of each regular file rules get to look at
+const HeadSize = 4096
+
+// Severity is how serious a finding is
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// MarshalText encodes the severity by name, for JSON output
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity name, e.g. "warning"
+func ParseSeverity(s string) (Severity, error) {
+	for _, severity := range []Severity{Info, Warning, Error} {
+		if strings.EqualFold(s, severity.String()) {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+// Finding is a problem reported by a rule
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.Path == "" {
+		return fmt.Sprintf("%s: %s: %s", f.Severity, f.Rule, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", f.Severity, f.Rule, f.Path, f.Message)
+}
+
+// Context is the package-wide information available to rules
+type Context struct {
+	Control *deb822.Paragraph
+}
+
+// Entry is a data archive entry being checked
+type Entry struct {
+	Header *tar.Header
+	// Head holds up to the first HeadSize bytes of regular files
+	Head []byte
+}
+
+// Reporter records a finding for path, which may be empty for package-wide findings
+type Reporter func(path string, format string, args ...interface{})
+
+// Rule is a single check. Rules see each entry of the data archive as it is read,
+// and can report package-wide findings once all entries have been seen.
+type Rule struct {
+	ID          string
+	Severity    Severity
+	Description string
+
+	// Check is called for each data archive entry
+	Check func(ctx *Context, e *Entry, report Reporter)
+	// Finish, if set, is called after the last entry
+	Finish func(ctx *Context, report Reporter)
+}
+
+var registry []*Rule
+
+// Register adds a rule to those returned by Rules, panicking on duplicate IDs
+func Register(rule *Rule) {
+	for _, r := range registry {
+		if r.ID == rule.ID {
+			panic(fmt.Sprintf("lint rule %q registered twice", rule.ID))
+		}
+	}
+	registry = append(registry, rule)
+}
+
+// Rules returns all registered rules, sorted by ID
+func Rules() []*Rule {
+	rules := append([]*Rule(nil), registry...)
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// Linter runs rules over a package as its data archive is read
+type Linter struct {
+	rules    []*Rule
+	ctx      *Context
+	findings []Finding
+}
+
+// New creates a linter for the package with the given control file
+func New(control *deb822.Paragraph, rules []*Rule) *Linter {
+	return &Linter{
+		rules: rules,
+		ctx: &Context{
+			Control: control,
+		},
+	}
+}
+
+// Entry runs the rules over a data archive entry. head is the start of regular files, see HeadSize.
+func (l *Linter) Entry(hdr *tar.Header, head []byte) {
+	e := &Entry{
+		Header: hdr,
+		Head:   head,
+	}
+	for _, rule := range l.rules {
+		if rule.Check != nil {
+			rule.Check(l.ctx, e, l.reporter(rule))
+		}
+	}
+}
+
+// Finish runs the package-wide part of the rules and returns every finding
+func (l *Linter) Finish() []Finding {
+	for _, rule := range l.rules {
+		if rule.Finish != nil {
+			rule.Finish(l.ctx, l.reporter(rule))
+		}
+	}
+	return l.findings
+}
+
+func (l *Linter) reporter(rule *Rule) Reporter {
+	return func(path string, format string, args ...interface{}) {
+		l.findings = append(l.findings, Finding{
+			Rule:     rule.ID,
+			Severity: rule.Severity,
+			Path:     path,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+}
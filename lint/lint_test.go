@@ -0,0 +1,167 @@
+package lint
+
+import (
+	"archive/tar"
+	"debug/elf"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/porty/deb-info/deb822"
+)
+
+// makeELF returns the start of a little-endian 64-bit ELF file, with a PT_INTERP program header if interp is set
+func makeELF(typ elf.Type, machine elf.Machine, interp bool) []byte {
+	head := make([]byte, 64+56)
+	copy(head, elf.ELFMAG)
+	head[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	head[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	head[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	order := binary.LittleEndian
+	order.PutUint16(head[16:], uint16(typ))
+	order.PutUint16(head[18:], uint16(machine))
+	order.PutUint64(head[32:], 64)
+	order.PutUint16(head[54:], 56)
+	order.PutUint16(head[56:], 1)
+	progType := elf.PT_LOAD
+	if interp {
+		progType = elf.PT_INTERP
+	}
+	order.PutUint32(head[64:], uint32(progType))
+	return head
+}
+
+// make32BitELF returns the start of a big-endian 32-bit ELF shared library
+func make32BitELF(machine elf.Machine) []byte {
+	head := make([]byte, 52)
+	copy(head, elf.ELFMAG)
+	head[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	head[elf.EI_DATA] = byte(elf.ELFDATA2MSB)
+	binary.BigEndian.PutUint16(head[16:], uint16(elf.ET_DYN))
+	binary.BigEndian.PutUint16(head[18:], uint16(machine))
+	return head
+}
+
+func TestRules(t *testing.T) {
+	amd64Program := makeELF(elf.ET_DYN, elf.EM_X86_64, true)
+	for _, test := range []struct {
+		name string
+		arch string
+		hdr  tar.Header
+		head []byte
+		// want are the IDs of the rules expected to report the entry
+		want []string
+	}{
+		{"git directory", "amd64", tar.Header{Name: "./usr/share/foo/.git/", Typeflag: tar.TypeDir, Mode: 0o755}, nil, []string{"git-directory"}},
+		{"git file", "amd64", tar.Header{Name: "./usr/share/foo/.git", Typeflag: tar.TypeReg, Mode: 0o644}, []byte("gitdir: ../x\n"), []string{"git-directory"}},
+		{"gitignore", "amd64", tar.Header{Name: "./usr/share/foo/.gitignore", Typeflag: tar.TypeReg, Mode: 0o644}, []byte("*.o\n"), nil},
+		{"executable", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o755}, amd64Program, nil},
+		{"executable without x", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o644}, amd64Program, []string{"binary-not-executable"}},
+		{"static executable without x", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o644}, makeELF(elf.ET_EXEC, elf.EM_X86_64, false), []string{"binary-not-executable"}},
+		{"group executable", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o610}, amd64Program, nil},
+		{"shared library without x", "amd64", tar.Header{Name: "./usr/lib/libfoo.so.1", Typeflag: tar.TypeReg, Mode: 0o644}, makeELF(elf.ET_DYN, elf.EM_X86_64, false), nil},
+		{"object file", "amd64", tar.Header{Name: "./usr/lib/foo.o", Typeflag: tar.TypeReg, Mode: 0o644}, makeELF(elf.ET_REL, elf.EM_X86_64, false), nil},
+		{"arm64 in amd64 package", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o755}, makeELF(elf.ET_DYN, elf.EM_AARCH64, true), []string{"elf-architecture-mismatch"}},
+		{"arm64 in arm64 package", "arm64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o755}, makeELF(elf.ET_DYN, elf.EM_AARCH64, true), nil},
+		{"32 bit in amd64 package", "amd64", tar.Header{Name: "./usr/lib/libfoo.so", Typeflag: tar.TypeReg, Mode: 0o644}, make32BitELF(elf.EM_X86_64), []string{"elf-architecture-mismatch"}},
+		{"binary in all package", "all", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o755}, makeELF(elf.ET_DYN, elf.EM_AARCH64, true), nil},
+		{"unknown architecture", "hurd-i386", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o755}, amd64Program, nil},
+		{"truncated ELF", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o644}, amd64Program[:40], nil},
+		{"symlink", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeSymlink, Linkname: "bar", Mode: 0o777}, nil, nil},
+		{"text", "amd64", tar.Header{Name: "./usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0o644}, []byte("#!/bin/sh\n"), nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			control, err := deb822.ParseParagraph("Package: foo\nVersion: 1.0\nArchitecture: " + test.arch + "\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			linter := New(control, Rules())
+			hdr := test.hdr
+			hdr.Size = int64(len(test.head))
+			linter.Entry(&hdr, test.head)
+
+			var got []string
+			for _, f := range linter.Finish() {
+				got = append(got, f.Rule)
+				if f.Path != test.hdr.Name {
+					t.Errorf("finding %s has path %q, want %q", f, f.Path, test.hdr.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got findings %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLinterFinish(t *testing.T) {
+	seen := 0
+	rules := []*Rule{{
+		ID:       "count",
+		Severity: Info,
+		Check: func(ctx *Context, e *Entry, report Reporter) {
+			seen++
+		},
+		Finish: func(ctx *Context, report Reporter) {
+			report("", "saw %d entries of %s", seen, ctx.Control.Package())
+		},
+	}}
+	control, err := deb822.ParseParagraph("Package: foo\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linter := New(control, rules)
+	for _, name := range []string{"./", "./a", "./b"} {
+		linter.Entry(&tar.Header{Name: name, Typeflag: tar.TypeReg}, nil)
+	}
+	want := []Finding{{Rule: "count", Severity: Info, Message: "saw 3 entries of foo"}}
+	if got := linter.Finish(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if s := want[0].String(); s != "info: count: saw 3 entries of foo" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	rules := Rules()
+	for i := 1; i < len(rules); i++ {
+		if rules[i-1].ID >= rules[i].ID {
+			t.Errorf("rules not sorted: %s before %s", rules[i-1].ID, rules[i].ID)
+		}
+	}
+	for _, rule := range rules {
+		if rule.Description == "" || rule.Check == nil && rule.Finish == nil {
+			t.Errorf("rule %s is incomplete", rule.ID)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate rule didn't panic")
+		}
+	}()
+	Register(&Rule{ID: rules[0].ID})
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want Severity
+		err  bool
+	}{
+		{"info", Info, false},
+		{"warning", Warning, false},
+		{"ERROR", Error, false},
+		{"fatal", 0, true},
+		{"", 0, true},
+	} {
+		got, err := ParseSeverity(test.s)
+		if got != test.want || (err != nil) != test.err {
+			t.Errorf("ParseSeverity(%q) = %v, %v", test.s, got, err)
+		}
+	}
+	if !(Info < Warning && Warning < Error) {
+		t.Error("severities are out of order")
+	}
+}
@@ -0,0 +1,66 @@
+package lint
+
+import (
+	"archive/tar"
+	"path"
+	"strings"
+
+	"github.com/porty/deb-info/deb"
+)
+
+func init() {
+	Register(gitDirectory)
+	Register(binaryNotExecutable)
+	Register(elfArchitectureMismatch)
+}
+
+var gitDirectory = &Rule{
+	ID:          "git-directory",
+	Severity:    Warning,
+	Description: "Package contains a .git directory, usually left over from building out of a checkout",
+	Check: func(ctx *Context, e *Entry, report Reporter) {
+		// the directory itself, or a .git file pointing at one for submodules and worktrees
+		name := strings.TrimSuffix(e.Header.Name, "/")
+		if path.Base(name) == ".git" {
+			report(e.Header.Name, "version control directory shipped in package")
+		}
+	},
+}
+
+var binaryNotExecutable = &Rule{
+	ID:          "binary-not-executable",
+	Severity:    Warning,
+	Description: "ELF executable without any execute permission bits",
+	Check: func(ctx *Context, e *Entry, report Reporter) {
+		if e.Header.Typeflag != tar.TypeReg || e.Header.FileInfo().Mode().Perm()&0o111 != 0 {
+			return
+		}
+		if h := parseELFHeader(e.Head); h != nil && h.IsExecutable() {
+			report(e.Header.Name, "ELF executable has mode %s", e.Header.FileInfo().Mode())
+		}
+	},
+}
+
+var elfArchitectureMismatch = &Rule{
+	ID:          "elf-architecture-mismatch",
+	Severity:    Error,
+	Description: "ELF file built for a different architecture than the control Architecture",
+	Check: func(ctx *Context, e *Entry, report Reporter) {
+		if e.Header.Typeflag != tar.TypeReg || ctx.Control == nil {
+			return
+		}
+		arch := ctx.Control.Architecture()
+		want, known := deb.ELFTargets[arch]
+		if !known {
+			// "all", or an architecture we don't know the ELF details of
+			return
+		}
+		h := parseELFHeader(e.Head)
+		if h == nil {
+			return
+		}
+		if h.Machine != want.Machine || h.Class != want.Class || h.Data != want.Data {
+			report(e.Header.Name, "%s %s %s binary in %s package", h.Machine, h.Class, h.Data, arch)
+		}
+	},
+}
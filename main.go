@@ -3,8 +3,6 @@ package main
 import (
 	"archive/tar"
 	"bytes"
-	"compress/bzip2"
-	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -14,94 +12,177 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
-	"text/tabwriter"
 	"time"
 
-	"github.com/gabriel-vasile/mimetype"
-	"github.com/ulikunitz/xz"
-
-	"github.com/porty/deb-info/ar"
+	"github.com/porty/deb-info/deb"
+	"github.com/porty/deb-info/deb822"
+	"github.com/porty/deb-info/lint"
 )
 
 func main() {
 	if err := errmain(); err != nil {
+		var status exitStatus
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
 		panic(err)
 	}
 }
 
-const signature = "!<arch>\n"
+// exitStatus is returned by commands that report their result through the exit status
+type exitStatus int
 
-type jsonResult struct {
-	Control map[string]string `json:"control"`
-	Data    []*FileInfo       `json:"data"`
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// commands are run when named by the first argument, otherwise the package is inspected
+var commands = map[string]func(args []string) error{
+	"build":            buildCommand,
+	"cat":              catCommand,
+	"compare-versions": compareVersionsCommand,
+	"diff":             diffCommand,
+	"extract":          extractCommand,
+	"lint":             lintCommand,
+	"recompress":       recompressCommand,
+	"repack":           repackCommand,
+	"satisfies":        satisfiesCommand,
+	"verify":           verifyCommand,
 }
 
 func errmain() error {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			return command(os.Args[2:])
+		}
+	}
+	return infoCommand()
+}
+
+type jsonResult struct {
+	Control   *deb822.Paragraph        `json:"control"`
+	Relations map[string]deb.Relations `json:"relations,omitempty"`
+	// ControlMembers are all of the control archive files, including maintainer scripts
+	ControlMembers []*jsonControlMember `json:"control_members"`
+	Data           []*deb.FileInfo      `json:"data"`
+	// Lint holds the findings of -lint, and is left out if there are none
+	Lint []lint.Finding `json:"lint,omitempty"`
+}
+
+func infoCommand() error {
 	jsonOutput := flag.Bool("json", false, "Output as JSON")
+	staticLibs := flag.Bool("static-libs", false, "List the objects and symbols of static libraries")
+	elfInfo := flag.Bool("elf", false, "Show the libraries, build ID and hardening of ELF executables and shared libraries")
+	hashes := flag.String("hash", "", "Comma separated hashes to compute for each file: md5, sha1, sha256, sha512")
+	showScripts := flag.Bool("show-scripts", false, "Print the maintainer scripts")
+	script := flag.String("script", "", "Print only the named control archive member, e.g. postinst")
+	human := flag.Bool("human", false, "Print sizes like 1.5K and 23M")
+	columnList := flag.String("columns", "", "Comma separated columns of the file listing: "+strings.Join(columnNames(), ", "))
+	sortBy := flag.String("sort", "", "Sort the file listing by a column, prefixed with - for descending order, e.g. -size")
+	noHeader := flag.Bool("no-header", false, "Print only the rows of the file listing, without the control file, headers or summary, for scripting")
+	highlightNonRoot := flag.Bool("highlight-non-root", false, "Highlight entries not owned by root:root")
+	runLint := flag.Bool("lint", false, "Also run the lint rules while reading the data archive, printing the findings after the listing")
 	flag.Parse()
 
-	filename := flag.Arg(0)
-
-	var r io.Reader
+	pkg, closePkg, err := openPackage(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closePkg()
 
-	if filename == "" {
-		r = os.Stdin
-	} else if strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://") {
-		rc, err := openHTTP(filename)
-		if err != nil {
-			return err
-		}
-		defer rc.Close()
-		r = rc
-	} else {
-		f, err := os.Open(filename)
-		if err != nil {
-			return fmt.Errorf("failed to open Debian package: %w", err)
-		}
-		defer f.Close()
-		r = f
+	if *script != "" {
+		return printControlMember(os.Stdout, pkg, *script)
 	}
 
-	var buf bytes.Buffer
-	_, err := io.Copy(&buf, io.LimitReader(r, 8))
+	hashNames, err := deb.ParseHashNames(*hashes)
 	if err != nil {
-		return fmt.Errorf("failed to read Debian package header: %w", err)
+		return err
 	}
-	if !bytes.Equal(buf.Bytes(), []byte(signature)) {
-		return errors.New("bad Debian package signature")
+	table, err := newTableOptions(*columnList, hashNames, *sortBy)
+	if err != nil {
+		return err
 	}
-
-	ar := ar.NewReader(r)
-
-	if err := readDebianBinary(ar); err != nil {
-		return fmt.Errorf("failed to read debian-binary: %w", err)
+	table.human = *human
+	table.noHeader = *noHeader
+	table.width = stdoutWidth()
+	if *highlightNonRoot {
+		table.highlight = func(row *listingRow) bool { return !row.fi.OwnedByRoot() }
+		table.color = stdoutIsTerminal() && os.Getenv("NO_COLOR") == ""
+	}
+	// hashes can be asked for as columns too
+	for _, c := range table.columns {
+		if c.hash && !containsString(hashNames, c.name) {
+			hashNames = append(hashNames, c.name)
+		}
 	}
 
-	control, err := readControl(ar)
-	if err != nil {
-		return fmt.Errorf("failed to read control file: %w", err)
+	opts := deb.DescribeOptions{
+		StaticLibraries: *staticLibs,
+		ELF:             *elfInfo,
+		Hashes:          hashNames,
 	}
 
 	if !*jsonOutput {
-		fmt.Println(control)
-		err = readDataToStdout(ar)
+		// without headers only the file listing's rows are printed, for scripts
+		if !table.noHeader {
+			fmt.Println(pkg.Control)
+			printControlMembers(os.Stdout, pkg)
+			if *showScripts {
+				printMaintainerScripts(os.Stdout, pkg)
+			}
+		}
+		var linter *lint.Linter
+		var visit dataVisitor
+		if *runLint {
+			control, err := pkg.ControlFields()
+			if err != nil {
+				return err
+			}
+			linter = lint.New(control, lint.Rules())
+			visit = lintVisitor(linter)
+		}
+		err = readDataToStdout(pkg, opts, table, visit)
 		if err != nil {
 			return fmt.Errorf("failed to read data file: %w", err)
 		}
+		if linter != nil {
+			findings := linter.Finish()
+			fmt.Printf("\nLint findings: %d\n", len(findings))
+			for _, f := range findings {
+				fmt.Println(f)
+			}
+		}
 	} else {
-		controlMap, err := controlToMap(control)
+		control, err := pkg.ControlFields()
 		if err != nil {
 			return err
 		}
-		files, err := readDataToSlice(ar)
+		relations, err := deb.ParseControlRelations(control)
+		if err != nil {
+			return err
+		}
+		var linter *lint.Linter
+		var visit dataVisitor
+		if *runLint {
+			linter = lint.New(control, lint.Rules())
+			visit = lintVisitor(linter)
+		}
+		files, err := readDataToSlice(pkg, opts, visit)
 		if err != nil {
 			return fmt.Errorf("failed to read data file: %w", err)
 		}
 
 		out := jsonResult{
-			Control: controlMap,
-			Data:    files,
+			Control:   control,
+			Relations: relations,
+
+			ControlMembers: controlMembersToJSON(pkg),
+			Data:           files,
+		}
+		if linter != nil {
+			out.Lint = linter.Finish()
 		}
 		_ = json.NewEncoder(os.Stdout).Encode(out)
 	}
@@ -109,6 +190,64 @@ func errmain() error {
 	return nil
 }
 
+// openPackage opens a package from a file, an HTTP(S) URL or standard input, like openSource,
+// returning a function to close it. Local files are read through an index of their
+// members, so the data archive can be read again without reopening the file.
+func openPackage(filename string) (*deb.Package, func() error, error) {
+	if f, size, ok := openLocal(filename); ok {
+		pkg, err := deb.OpenReaderAt(f, size)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return pkg, f.Close, nil
+	}
+
+	r, err := openSource(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkg, err := deb.Open(r)
+	if err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+	return pkg, r.Close, nil
+}
+
+// openLocal opens filename and returns its size if it is a regular file, which can be read
+// at any offset. Anything else, including errors, is left to openSource.
+func openLocal(filename string) (*os.File, int64, bool) {
+	if filename == "" || filename == "-" || strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://") {
+		return nil, 0, false
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, false
+	}
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, 0, false
+	}
+	return f, fi.Size(), true
+}
+
+// openSource opens a package from a file, an HTTP(S) URL or, if filename is empty or "-", standard input
+func openSource(filename string) (io.ReadCloser, error) {
+	if filename == "" || filename == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	if strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://") {
+		return openHTTP(filename)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Debian package: %w", err)
+	}
+	return f, nil
+}
+
 func openHTTP(filename string) (io.ReadCloser, error) {
 	c := &http.Client{
 		Transport: &http.Transport{
@@ -155,255 +294,172 @@ func openHTTP(filename string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
-func readDebianBinary(ar *ar.Reader) error {
-	fi, err := ar.ReadFile()
-	if err != nil {
-		return err
-	}
-	const debianBinary = "debian-binary"
-	if fi.Name != debianBinary {
-		return fmt.Errorf("expected file %q, got %q", debianBinary, fi.Name)
-	}
-	if fi.Size != 4 {
-		return fmt.Errorf("expected size %d, got %q", 4, fi.Size)
-	}
-	var buf bytes.Buffer
-
-	read, err := io.Copy(&buf, fi.Reader)
-	if err != nil {
-		return fmt.Errorf("failed to read: %w", err)
-	}
-	if read != 4 {
-		return fmt.Errorf("failed to read: expected %d bytes, read %d bytes", 4, read)
-	}
-	if buf.String() != "2.0\n" {
-		return errors.New("invalid debian-binary value")
-	}
-	return nil
-}
+// dataVisitor is called by readData for each data archive entry, with head holding up to
+// the first lint.HeadSize bytes of regular files. head is only valid until it returns.
+type dataVisitor func(hdr *tar.Header, fi *deb.FileInfo, head []byte)
 
-func readControl(ar *ar.Reader) (string, error) {
-	fi, err := ar.ReadFile()
+// readData describes every entry of the data archive in a single pass, so listing and
+// linting need only read the package once
+func readData(pkg *deb.Package, opts deb.DescribeOptions, visit dataVisitor) error {
+	data, err := pkg.Data()
 	if err != nil {
-		return "", err
-	}
-
-	if fi.Size > 100*1024 {
-		return "", fmt.Errorf("control archive seems to large at %d bytes", fi.Size)
-	}
-
-	var r io.Reader
-
-	switch fi.Name {
-	case "control.tar.gz":
-		gr, err := gzip.NewReader(fi.Reader)
-		if err != nil {
-			return "", fmt.Errorf("failed to initialize gzip reader: %w", err)
-		}
-		defer gr.Close()
-		r = gr
-	case "control.tar.xz":
-		xzr, err := xz.NewReader(fi.Reader)
-		if err != nil {
-			return "", fmt.Errorf("failed to initialize xz reader: %w", err)
-		}
-		r = xzr
-	case "control.tar.bz":
-		r = bzip2.NewReader(fi.Reader)
-	default:
-		return "", fmt.Errorf("unknown/unhandled control file extension: %s", fi.Name)
+		return err
 	}
+	defer data.Close()
 
-	tr := tar.NewReader(r)
+	buf := make([]byte, lint.HeadSize)
 	for {
-		tarFile, err := tr.Next()
+		hdr, err := data.Next()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return "", fmt.Errorf("failed to read file from control archive: %w", err)
-		}
-
-		if tarFile.Name == "./control" {
-			var buf bytes.Buffer
-			if _, err := io.Copy(&buf, tr); err != nil {
-				return "", fmt.Errorf("failed to read control file: %w", err)
-			}
-			return buf.String(), nil
-		}
-	}
-
-	return "", errors.New("failed to find control file in control archive")
-}
-
-func controlToMap(control string) (map[string]string, error) {
-
-	lines := strings.Split(control, "\n")
-	m := map[string]string{}
-	var lastKey string
-
-	for _, line := range lines {
-		if line == "" {
-			continue
+			return err
 		}
 
-		if strings.HasPrefix(line, " ") {
-			// multi line value
-			if lastKey == "" {
-				return nil, errors.New("bad continuation line")
+		var head []byte
+		var r io.Reader = data
+		if hdr.Typeflag == tar.TypeReg {
+			n, err := io.ReadFull(data, buf)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return fmt.Errorf("failed to read %s: %w", hdr.Name, err)
 			}
-			m[lastKey] = m[lastKey] + "\n" + strings.TrimPrefix(line, " ")
-			continue
-		}
-
-		// normal line
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("failed to read control: invalid line %q", line)
+			head = buf[:n]
+			r = io.MultiReader(bytes.NewReader(head), data)
 		}
-		if _, exists := m[parts[0]]; exists {
-			return nil, fmt.Errorf("failed to read control: duplicate %q", parts[0])
-		}
-		m[parts[0]] = strings.TrimSpace(parts[1])
-		lastKey = parts[0]
-	}
-
-	return m, nil
-}
-
-func readDataToStdout(ar *ar.Reader) error {
-	fi, err := ar.ReadFile()
-	if err != nil {
-		return err
-	}
 
-	var r io.Reader
-
-	switch fi.Name {
-	case "data.tar.gz":
-		gr, err := gzip.NewReader(fi.Reader)
-		if err != nil {
-			return fmt.Errorf("failed to initialize gzip reader: %w", err)
-		}
-		defer gr.Close()
-		r = gr
-	case "data.tar.xz":
-		xzr, err := xz.NewReader(fi.Reader)
+		fi, err := describe(hdr, r, opts)
 		if err != nil {
-			return fmt.Errorf("failed to initialize xz reader: %w", err)
+			return err
 		}
-		r = xzr
-	case "data.tar.bz":
-		r = bzip2.NewReader(fi.Reader)
-	default:
-		return fmt.Errorf("unknown/unhandled data file extension: %s", fi.Name)
+		visit(hdr, fi, head)
 	}
+}
 
-	w := tabwriter.NewWriter(os.Stdout, 10, 2, 4, ' ', 0)
-	w.Write([]byte("Name\tMode\tSize\tMIME\n"))
-
+func readDataToStdout(pkg *deb.Package, opts deb.DescribeOptions, table *tableOptions, visit dataVisitor) error {
 	fileCount := 0
 	dirCount := 0
 	totalFileSize := int64(0)
+	nonRootCount := 0
 
-	tr := tar.NewReader(r)
-	for {
-		f, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read file from data archive: %w", err)
-		}
+	var rows []*listingRow
+	var libs, binaries []*deb.FileInfo
 
-		mimeStr := ""
+	err := readData(pkg, opts, func(f *tar.Header, fi *deb.FileInfo, head []byte) {
 		switch f.Typeflag {
 		case tar.TypeDir:
 			dirCount++
-			mimeStr = "<DIR>"
-		case tar.TypeSymlink, tar.TypeLink:
-			mimeStr = "-> " + f.Linkname
 		case tar.TypeReg:
 			fileCount++
 			totalFileSize += f.Size
-			if mime, _ := mimetype.DetectReader(tr); mime != nil {
-				mimeStr = mime.String()
-			} else {
-				mimeStr = "(unknown)"
+			if fi.StaticLibrary != nil {
+				libs = append(libs, fi)
+			}
+			if fi.ELF != nil {
+				binaries = append(binaries, fi)
 			}
-		default:
-			mimeStr = "(not handled)"
 		}
 
-		fi := f.FileInfo()
-		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", f.Name, fi.Mode().String(), f.Size, mimeStr)
+		if !fi.OwnedByRoot() {
+			nonRootCount++
+		}
+		rows = append(rows, &listingRow{hdr: f, fi: fi})
+		if visit != nil {
+			visit(f, fi, head)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	renderTable(os.Stdout, rows, table)
+	if table.noHeader {
+		return nil
 	}
-	w.Flush()
 
+	totalSize := strconv.FormatInt(totalFileSize, 10)
+	if table.human {
+		totalSize = humanSize(totalFileSize)
+	}
 	fmt.Println()
-	fmt.Printf("File count: %d\nDirectory count: %d\nTotal file size: %d\n", fileCount, dirCount, totalFileSize)
+	fmt.Printf("File count: %d\nDirectory count: %d\nTotal file size: %s\n", fileCount, dirCount, totalSize)
+	if table.highlight != nil {
+		fmt.Printf("Not owned by root:root: %d\n", nonRootCount)
+	}
+
+	for _, fi := range libs {
+		printStaticLibrary(os.Stdout, fi.Name, fi.StaticLibrary)
+	}
+	for _, fi := range binaries {
+		printELF(os.Stdout, fi.Name, fi.ELF)
+	}
 
 	return nil
 }
 
-type FileInfo struct {
-	Name string `json:"name"`
-	// directories will have Size=0, and will be omitted
-	// empty files will also be omitted :(
-	Size int64  `json:"size,omitempty"`
-	Mode string `json:"mode,omitempty"`
-	MIME string `json:"mime,omitempty"`
+func printStaticLibrary(w io.Writer, name string, lib *deb.StaticLibrary) {
+	fmt.Fprintf(w, "\nStatic library %s\n", name)
+	for _, obj := range lib.Objects {
+		if len(obj.Symbols) == 0 {
+			fmt.Fprintf(w, "    %s\n", obj.Name)
+			continue
+		}
+		fmt.Fprintf(w, "    %s: %s\n", obj.Name, strings.Join(obj.Symbols, ", "))
+	}
 }
 
-func readDataToSlice(ar *ar.Reader) ([]*FileInfo, error) {
-	fi, err := ar.ReadFile()
-	if err != nil {
-		return nil, err
+func printELF(w io.Writer, name string, info *deb.ELFInfo) {
+	arch := strings.Join(info.Architectures, "/")
+	if arch == "" {
+		arch = "unknown architecture"
 	}
-	// TODO: handle data.tar.bz
-	const controlTarGz = "data.tar.gz"
-	if fi.Name != controlTarGz {
-		return nil, fmt.Errorf("expected file %q, got %q", controlTarGz, fi.Name)
+	fmt.Fprintf(w, "\nELF %s\n", name)
+	fmt.Fprintf(w, "    Type: %s, Machine: %s (%s), Class: %s\n", info.Type, info.Machine, arch, info.Class)
+	if info.SONAME != "" {
+		fmt.Fprintf(w, "    SONAME: %s\n", info.SONAME)
 	}
-
-	gr, err := gzip.NewReader(fi.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize gzip reader: %w", err)
+	if len(info.Needed) > 0 {
+		fmt.Fprintf(w, "    Needed: %s\n", strings.Join(info.Needed, ", "))
 	}
-	gr.Close()
-
-	result := []*FileInfo{}
+	if len(info.RPath) > 0 {
+		fmt.Fprintf(w, "    RPATH: %s\n", strings.Join(info.RPath, ":"))
+	}
+	if len(info.RunPath) > 0 {
+		fmt.Fprintf(w, "    RUNPATH: %s\n", strings.Join(info.RunPath, ":"))
+	}
+	if info.BuildID != "" {
+		fmt.Fprintf(w, "    Build ID: %s\n", info.BuildID)
+	}
+	fmt.Fprintf(w, "    Stripped: %s, PIE: %s, RELRO: %s, NX: %s\n", yesNo(info.Stripped), yesNo(info.PIE), info.RELRO, yesNo(info.NX))
+}
 
-	tr := tar.NewReader(gr)
-	for {
-		f, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file from data archive: %w", err)
-		}
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
 
-		mimeStr := ""
-		switch f.Typeflag {
-		case tar.TypeDir:
-		case tar.TypeSymlink, tar.TypeLink:
-			mimeStr = "-> " + f.Linkname
-		case tar.TypeReg:
-			if mime, _ := mimetype.DetectReader(tr); mime != nil {
-				mimeStr = mime.String()
-			}
-		default:
-			mimeStr = "???"
+func readDataToSlice(pkg *deb.Package, opts deb.DescribeOptions, visit dataVisitor) ([]*deb.FileInfo, error) {
+	result := []*deb.FileInfo{}
+	err := readData(pkg, opts, func(hdr *tar.Header, fi *deb.FileInfo, head []byte) {
+		result = append(result, fi)
+		if visit != nil {
+			visit(hdr, fi, head)
 		}
-
-		result = append(result, &FileInfo{
-			Name: f.Name,
-			Size: f.Size,
-			Mode: f.FileInfo().Mode().String(),
-			MIME: mimeStr,
-		})
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return result, nil
 }
+
+// describe calls deb.Describe, logging files that can't be parsed rather than stopping
+func describe(hdr *tar.Header, r io.Reader, opts deb.DescribeOptions) (*deb.FileInfo, error) {
+	fi, err := deb.Describe(hdr, r, opts)
+	var inspectErr *deb.InspectError
+	if errors.As(err, &inspectErr) {
+		log.Print(err)
+		return fi, nil
+	}
+	return fi, err
+}
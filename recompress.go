@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/porty/deb-info/deb"
+)
+
+// recompressCommand changes the compression of the control and data archives
+func recompressCommand(args []string) error {
+	fs := flag.NewFlagSet("recompress", flag.ExitOnError)
+	var opts deb.RecompressOptions
+	fs.StringVar(&opts.Control, "control", "", "Compression for the control archive: gzip, xz, zstd or none (default unchanged)")
+	fs.StringVar(&opts.Data, "data", "", "Compression for the data archive: gzip, xz, zstd or none (default unchanged)")
+	fs.IntVar(&opts.ControlLevel, "control-level", 0, "Control archive compression level: 1-9 for gzip and xz, 1-22 for zstd (default the format's default)")
+	fs.IntVar(&opts.DataLevel, "data-level", 0, "Data archive compression level: 1-9 for gzip and xz, 1-22 for zstd (default the format's default)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info recompress [-control NAME] [-data NAME] IN OUT")
+		fmt.Fprintln(fs.Output(), "IN can be a file, an HTTP(S) URL, or - for standard input.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || (opts.Control == "" && opts.Data == "") {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	if sameFile(fs.Arg(0), fs.Arg(1)) {
+		fmt.Fprintln(os.Stderr, "IN and OUT must be different files")
+		return exitStatus(2)
+	}
+
+	r, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	in := &countingReader{r: r}
+
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	out := &countingWriter{w: f}
+	members, err := deb.Recompress(in, out, opts)
+	if err != nil {
+		f.Close()
+		os.Remove(fs.Arg(1))
+		return fmt.Errorf("failed to recompress package: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		if m.NewCompression == "" {
+			fmt.Printf("%-16s %10d  (copied)\n", m.OldName, m.OldSize)
+			continue
+		}
+		fmt.Printf("%-16s %10d -> %-16s %10d  %s -> %s, %s\n", m.OldName, m.OldSize, m.NewName, m.NewSize,
+			m.OldCompression, m.NewCompression, sizeChange(m.OldSize, m.NewSize))
+	}
+	fmt.Printf("Package size: %d -> %d bytes, %s\n", in.n, out.n, sizeChange(in.n, out.n))
+	return nil
+}
+
+// sizeChange describes the new size as a percentage of the old one
+func sizeChange(oldSize int64, newSize int64) string {
+	if oldSize == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", float64(newSize)*100/float64(oldSize))
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
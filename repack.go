@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/porty/deb-info/deb"
+	"github.com/porty/deb-info/deb822"
+)
+
+// repackCommand changes the control fields of a package, leaving the rest of it alone
+func repackCommand(args []string) error {
+	fs := flag.NewFlagSet("repack", flag.ExitOnError)
+	var set, unset stringList
+	fs.Var(&set, "set", "Set a control field, as `Field=Value` (repeatable)")
+	fs.Var(&unset, "unset", "Remove a control `Field` (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info repack [-set Field=Value]... [-unset Field]... IN OUT")
+		fmt.Fprintln(fs.Output(), "IN can be a file, an HTTP(S) URL, or - for standard input.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || len(set)+len(unset) == 0 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	type assignment struct{ field, value string }
+	var assignments []assignment
+	for _, s := range set {
+		field, value, ok := strings.Cut(s, "=")
+		if !ok || !validFieldName(field) {
+			fmt.Fprintf(os.Stderr, "invalid -set %q, expected Field=Value\n", s)
+			return exitStatus(2)
+		}
+		// an empty field isn't valid in a control file, so -unset is needed to remove one
+		if strings.TrimSpace(value) == "" {
+			fmt.Fprintf(os.Stderr, "invalid -set %q, the value is empty, use -unset %s to remove the field\n", s, field)
+			return exitStatus(2)
+		}
+		assignments = append(assignments, assignment{field, value})
+	}
+	for _, field := range unset {
+		if !validFieldName(field) {
+			fmt.Fprintf(os.Stderr, "invalid -unset %q, expected a field name\n", field)
+			return exitStatus(2)
+		}
+	}
+
+	if sameFile(fs.Arg(0), fs.Arg(1)) {
+		fmt.Fprintln(os.Stderr, "IN and OUT must be different files")
+		return exitStatus(2)
+	}
+
+	r, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	err = deb.Repack(r, f, func(control *deb822.Paragraph) error {
+		for _, field := range unset {
+			if !control.Del(field) {
+				fmt.Fprintf(os.Stderr, "warning: no %s field to remove\n", field)
+			}
+		}
+		for _, a := range assignments {
+			control.Set(a.field, a.value)
+		}
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		os.Remove(fs.Arg(1))
+		return fmt.Errorf("failed to repack package: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", fs.Arg(1))
+	return nil
+}
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// validFieldName reports whether name can be used as a control field name
+func validFieldName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "#") || strings.HasPrefix(name, "-") {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r > '~' || r == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// sameFile reports whether two paths are the same existing file
+func sameFile(a string, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/porty/deb-info/deb"
+)
+
+// defaultColumns are shown when -columns isn't given, with any -hash columns before the MIME type
+var defaultColumns = []string{"name", "mode", "owner", "size", "mtime", "mime"}
+
+// cells are padded like the tabwriter the listing used to be written with
+const (
+	cellMinWidth = 10
+	cellPadding  = 4
+	// truncated cells are never made narrower than this
+	truncateMinWidth = 16
+)
+
+// listingRow is a data archive entry in the file listing
+type listingRow struct {
+	hdr *tar.Header
+	fi  *deb.FileInfo
+}
+
+// column is a field of the file listing table
+type column struct {
+	name  string
+	title string
+	value func(row *listingRow, opts *tableOptions) string
+	// less orders rows for -sort, comparing values if nil
+	less func(a, b *listingRow) bool
+	// truncate is where long values are shortened to fit the terminal, if they can be
+	truncate truncateMode
+	// hash columns need the hash computing while the data archive is read
+	hash bool
+}
+
+type truncateMode int
+
+const (
+	noTruncate truncateMode = iota
+	truncateStart
+	truncateEnd
+)
+
+var columns = map[string]*column{
+	"name": {
+		title:    "Name",
+		value:    func(row *listingRow, opts *tableOptions) string { return row.fi.Name },
+		truncate: truncateStart,
+	},
+	"mode": {
+		title: "Mode",
+		value: func(row *listingRow, opts *tableOptions) string { return row.fi.Mode },
+	},
+	"size": {
+		title: "Size",
+		value: func(row *listingRow, opts *tableOptions) string {
+			if opts.human {
+				return humanSize(row.fi.Size)
+			}
+			return strconv.FormatInt(row.fi.Size, 10)
+		},
+		less: func(a, b *listingRow) bool { return a.fi.Size < b.fi.Size },
+	},
+	"mime": {
+		title:    "MIME",
+		value:    func(row *listingRow, opts *tableOptions) string { return mimeLabel(row) },
+		truncate: truncateEnd,
+	},
+	"owner": {
+		title: "Owner",
+		value: func(row *listingRow, opts *tableOptions) string { return row.fi.Owner() },
+	},
+	"mtime": {
+		title: "Modified",
+		value: func(row *listingRow, opts *tableOptions) string {
+			return row.fi.ModTime.Format("2006-01-02 15:04:05")
+		},
+		less: func(a, b *listingRow) bool { return a.fi.ModTime.Before(b.fi.ModTime) },
+	},
+}
+
+func init() {
+	for name, c := range columns {
+		c.name = name
+	}
+	for _, name := range deb.HashNames {
+		name := name
+		columns[name] = &column{
+			name:  name,
+			title: strings.ToUpper(name),
+			hash:  true,
+			value: func(row *listingRow, opts *tableOptions) string { return row.fi.Hash(name) },
+		}
+	}
+}
+
+// mimeLabel is the MIME column text, which also describes entries that aren't regular files
+func mimeLabel(row *listingRow) string {
+	fi := row.fi
+	switch fi.Type {
+	case deb.TypeDir:
+		return "<DIR>"
+	case deb.TypeSymlink:
+		return "-> " + fi.LinkTarget
+	case deb.TypeHardLink:
+		return "=> " + fi.LinkTarget + " (hard link)"
+	case deb.TypeChar:
+		return fmt.Sprintf("<CHR %d,%d>", fi.Device.Major, fi.Device.Minor)
+	case deb.TypeBlock:
+		return fmt.Sprintf("<BLK %d,%d>", fi.Device.Major, fi.Device.Minor)
+	case deb.TypeFIFO:
+		return "<FIFO>"
+	case deb.TypeFile:
+		if fi.MIME == "" {
+			return "(unknown)"
+		}
+		return fi.MIME
+	default:
+		return fmt.Sprintf("(not handled: type %q)", row.hdr.Typeflag)
+	}
+}
+
+// humanSize formats a size like ls -h, e.g. 1.5K or 23M
+func humanSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return strconv.FormatInt(size, 10)
+	}
+	value := float64(size)
+	unit := -1
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if value < 10 {
+		return fmt.Sprintf("%.1f%c", value, units[unit])
+	}
+	return fmt.Sprintf("%.0f%c", value, units[unit])
+}
+
+// tableOptions controls how the file listing is rendered
+type tableOptions struct {
+	columns  []*column
+	human    bool
+	noHeader bool
+	sortBy   *column
+	reverse  bool
+	// width is the width to truncate lines to, or 0 to not truncate
+	width int
+	// highlight, if set, picks out rows to draw attention to. They are shown in red
+	// if color is set, and marked with a leading "!" otherwise.
+	highlight func(row *listingRow) bool
+	color     bool
+}
+
+// newTableOptions parses the -columns and -sort flags. Without -columns the default
+// columns are used, with a column for each of hashes.
+func newTableOptions(columnList string, hashes []string, sortBy string) (*tableOptions, error) {
+	opts := &tableOptions{}
+	var err error
+	if columnList == "" {
+		names := append([]string(nil), defaultColumns[:len(defaultColumns)-1]...)
+		names = append(names, hashes...)
+		names = append(names, defaultColumns[len(defaultColumns)-1])
+		columnList = strings.Join(names, ",")
+	}
+	opts.columns, err = parseColumns(columnList)
+	if err != nil {
+		return nil, err
+	}
+	opts.sortBy, opts.reverse, err = parseSort(sortBy)
+	if err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseColumns parses a comma separated list of column names
+func parseColumns(s string) ([]*column, error) {
+	var result []*column
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		c, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(columnNames(), ", "))
+		}
+		result = append(result, c)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return result, nil
+}
+
+func columnNames() []string {
+	var names []string
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSort parses the -sort flag, a column name optionally prefixed with "-" for descending order
+func parseSort(s string) (*column, bool, error) {
+	if s == "" {
+		return nil, false, nil
+	}
+	reverse := strings.HasPrefix(s, "-")
+	name := strings.ToLower(strings.TrimPrefix(s, "-"))
+	c, ok := columns[name]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown sort column %q, expected one of %s", name, strings.Join(columnNames(), ", "))
+	}
+	return c, reverse, nil
+}
+
+// stdoutIsTerminal reports whether standard output is written to a terminal rather than a file or pipe
+func stdoutIsTerminal() bool {
+	stat, err := os.Stdout.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// stdoutWidth is the width of the terminal standard output is written to, or 0 if it isn't a terminal
+func stdoutWidth() int {
+	if !stdoutIsTerminal() {
+		return 0
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return terminalWidth(os.Stdout)
+}
+
+// renderTable writes rows as a table, sorting them first if asked to
+func renderTable(w io.Writer, rows []*listingRow, opts *tableOptions) {
+	if opts.sortBy != nil {
+		c := opts.sortBy
+		less := c.less
+		if less == nil {
+			less = func(a, b *listingRow) bool { return c.value(a, opts) < c.value(b, opts) }
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			if opts.reverse {
+				return less(rows[j], rows[i])
+			}
+			return less(rows[i], rows[j])
+		})
+	}
+
+	highlighted := map[int]bool{}
+	var cells [][]string
+	if !opts.noHeader {
+		var header []string
+		for _, c := range opts.columns {
+			header = append(header, c.title)
+		}
+		cells = append(cells, header)
+	}
+	for _, row := range rows {
+		var line []string
+		for _, c := range opts.columns {
+			line = append(line, c.value(row, opts))
+		}
+		if opts.highlight != nil && opts.highlight(row) {
+			highlighted[len(cells)] = true
+		}
+		cells = append(cells, line)
+	}
+
+	// without color, lines start with a marker for highlighted rows
+	marker := opts.highlight != nil && !opts.color
+
+	widths := make([]int, len(opts.columns))
+	for _, line := range cells {
+		for i, cell := range line {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	if opts.width > 0 {
+		width := opts.width
+		if marker {
+			width -= 2
+		}
+		shrinkColumns(widths, opts.columns, width)
+	}
+
+	for row, line := range cells {
+		var b strings.Builder
+		switch {
+		case opts.color && highlighted[row]:
+			b.WriteString("\x1b[1;31m")
+		case marker && highlighted[row]:
+			b.WriteString("! ")
+		case marker:
+			b.WriteString("  ")
+		}
+		for i, cell := range line {
+			cell = truncateCell(cell, widths[i], opts.columns[i].truncate)
+			b.WriteString(cell)
+			if i == len(line)-1 {
+				break
+			}
+			b.WriteString(strings.Repeat(" ", cellWidth(widths[i])-utf8.RuneCountInString(cell)))
+		}
+		if opts.color && highlighted[row] {
+			b.WriteString("\x1b[0m")
+		}
+		b.WriteByte('\n')
+		io.WriteString(w, b.String())
+	}
+}
+
+// cellWidth is the width of a column including padding, for all but the last column
+func cellWidth(textWidth int) int {
+	if textWidth+cellPadding < cellMinWidth {
+		return cellMinWidth
+	}
+	return textWidth + cellPadding
+}
+
+// shrinkColumns narrows truncatable columns, in order, until lines fit in width
+func shrinkColumns(widths []int, columns []*column, width int) {
+	total := widths[len(widths)-1]
+	for _, w := range widths[:len(widths)-1] {
+		total += cellWidth(w)
+	}
+	for i, c := range columns {
+		if total <= width {
+			return
+		}
+		if c.truncate == noTruncate || widths[i] <= truncateMinWidth {
+			continue
+		}
+		shrink := total - width
+		if widths[i]-shrink < truncateMinWidth {
+			shrink = widths[i] - truncateMinWidth
+		}
+		widths[i] -= shrink
+		total -= shrink
+	}
+}
+
+// truncateCell shortens s to width characters, marking where it was cut with "..."
+func truncateCell(s string, width int, mode truncateMode) string {
+	n := utf8.RuneCountInString(s)
+	if mode == noTruncate || n <= width {
+		return s
+	}
+	runes := []rune(s)
+	keep := width - 3
+	if mode == truncateStart {
+		// the end of a path says more than the start
+		return "..." + string(runes[n-keep:])
+	}
+	return string(runes[:keep]) + "..."
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// terminalWidth is unknown on this platform, so tables aren't truncated
+func terminalWidth(f *os.File) int {
+	return 0
+}
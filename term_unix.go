@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalWidth asks the terminal f is attached to for its width, returning 0 if it can't
+func terminalWidth(f *os.File) int {
+	var size struct {
+		Rows, Columns, XPixels, YPixels uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0
+	}
+	return int(size.Columns)
+}
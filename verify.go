@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// verifyCommand checks the data archive against the md5sums control member, exiting 1 on any problem
+func verifyCommand(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deb-info verify [-json] [PACKAGE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	pkg, closePkg, err := openPackage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closePkg()
+	result, err := pkg.Verify()
+	if err != nil {
+		return fmt.Errorf("failed to verify package: %w", err)
+	}
+
+	if *jsonOutput {
+		_ = json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		for _, m := range result.Mismatched {
+			fmt.Printf("MISMATCH %s: expected %s, got %s\n", m.Path, m.Expected, m.Actual)
+		}
+		for _, name := range result.Missing {
+			fmt.Printf("MISSING  %s: listed in md5sums but not in the data archive\n", name)
+		}
+		for _, name := range result.Extra {
+			fmt.Printf("EXTRA    %s: not listed in md5sums\n", name)
+		}
+		problems := len(result.Mismatched) + len(result.Missing) + len(result.Extra)
+		if problems == 0 {
+			fmt.Printf("Checked %d files: OK\n", result.Checked)
+		} else {
+			fmt.Printf("Checked %d files: %d problems\n", result.Checked, problems)
+		}
+	}
+
+	if !result.OK() {
+		return exitStatus(1)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package version implements Debian package version parsing and ordering, as dpkg does
+package version
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed "[epoch:]upstream_version[-debian_revision]"
+type Version struct {
+	Epoch    int
+	Upstream string
+	Revision string
+}
+
+// Parse splits a version into its epoch, upstream version and revision
+func Parse(s string) (Version, error) {
+	var v Version
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return v, errors.New("version string is empty")
+	}
+	if strings.ContainsAny(s, " \t\n") {
+		return v, fmt.Errorf("version %q has embedded spaces", s)
+	}
+
+	if epoch, rest, ok := strings.Cut(s, ":"); ok {
+		n, err := strconv.Atoi(epoch)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("version %q has an invalid epoch", s)
+		}
+		v.Epoch = n
+		s = rest
+	}
+
+	if i := strings.LastIndexByte(s, '-'); i != -1 {
+		v.Revision = s[i+1:]
+		if v.Revision == "" {
+			return v, fmt.Errorf("version %q has an empty revision", s)
+		}
+		s = s[:i]
+	}
+	v.Upstream = s
+	if v.Upstream == "" {
+		return v, errors.New("version has an empty upstream version")
+	}
+
+	for _, c := range v.Upstream {
+		if !isAlnum(c) && !strings.ContainsRune(".-+~:", c) {
+			return v, fmt.Errorf("invalid character %q in upstream version %q", c, v.Upstream)
+		}
+	}
+	for _, c := range v.Revision {
+		if !isAlnum(c) && !strings.ContainsRune(".+~", c) {
+			return v, fmt.Errorf("invalid character %q in revision %q", c, v.Revision)
+		}
+	}
+
+	return v, nil
+}
+
+// String formats the version, leaving out a zero epoch and an empty revision
+func (v Version) String() string {
+	s := v.Upstream
+	if v.Epoch != 0 {
+		s = strconv.Itoa(v.Epoch) + ":" + s
+	}
+	if v.Revision != "" {
+		s += "-" + v.Revision
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if a is lower than, equal to or higher than b
+func (a Version) Compare(b Version) int {
+	if a.Epoch != b.Epoch {
+		if a.Epoch < b.Epoch {
+			return -1
+		}
+		return 1
+	}
+	if c := compareString(a.Upstream, b.Upstream); c != 0 {
+		return c
+	}
+	return compareString(a.Revision, b.Revision)
+}
+
+// Compare parses and compares two version strings
+func Compare(a, b string) (int, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// Satisfies reports whether "a op b" holds, where op is one of the relation operators
+// "<<", "<=", "=", ">=" and ">>", or one of dpkg --compare-versions' "lt", "le", "eq", "ne", "ge" and "gt"
+func Satisfies(a string, op string, b string) (bool, error) {
+	c, err := Compare(a, b)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case "<<", "lt":
+		return c < 0, nil
+	case "<=", "le", "<":
+		return c <= 0, nil
+	case "=", "eq":
+		return c == 0, nil
+	case "ne":
+		return c != 0, nil
+	case ">=", "ge", ">":
+		return c >= 0, nil
+	case ">>", "gt":
+		return c > 0, nil
+	default:
+		return false, fmt.Errorf("unknown version operator %q", op)
+	}
+}
+
+// compareString is dpkg's verrevcmp: alternating runs of non-digits, compared with
+// order, and digits, compared numerically
+func compareString(a, b string) int {
+	for a != "" || b != "" {
+		firstDiff := 0
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			ac, bc := 0, 0
+			if a != "" {
+				ac = order(a[0])
+			}
+			if b != "" {
+				bc = order(b[0])
+			}
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			a, b = a[1:], b[1:]
+		}
+		for a != "" && a[0] == '0' {
+			a = a[1:]
+		}
+		for b != "" && b[0] == '0' {
+			b = b[1:]
+		}
+		for a != "" && isDigit(a[0]) && b != "" && isDigit(b[0]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[0]) - int(b[0])
+			}
+			a, b = a[1:], b[1:]
+		}
+		if a != "" && isDigit(a[0]) {
+			return 1
+		}
+		if b != "" && isDigit(b[0]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// order sorts "~" before everything, even the end of the string, then letters before other characters
+func order(c byte) int {
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	}
+	if n > 0 {
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c rune) bool {
+	return c < 0x80 && (isDigit(byte(c)) || isAlpha(byte(c)))
+}
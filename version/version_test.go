@@ -0,0 +1,169 @@
+package version
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want Version
+		// str is what String gives back, if different from s
+		str string
+	}{
+		{"1.0", Version{Upstream: "1.0"}, ""},
+		{"1.0-1", Version{Upstream: "1.0", Revision: "1"}, ""},
+		{"2:1.0-1", Version{Epoch: 2, Upstream: "1.0", Revision: "1"}, ""},
+		{"0:1.0", Version{Upstream: "1.0"}, "1.0"},
+		{"1.0-2-3", Version{Upstream: "1.0-2", Revision: "3"}, ""},
+		{"1:2:3", Version{Epoch: 1, Upstream: "2:3"}, ""},
+		{"1.0~rc1+dfsg-1ubuntu0.1", Version{Upstream: "1.0~rc1+dfsg", Revision: "1ubuntu0.1"}, ""},
+		{" 1.0 ", Version{Upstream: "1.0"}, "1.0"},
+	} {
+		got, err := Parse(test.s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.s, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.s, got, test.want)
+		}
+		want := test.str
+		if want == "" {
+			want = test.s
+		}
+		if got.String() != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", test.s, got.String(), want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"   ",
+		"1.0 1",
+		"a:1.0",
+		"-1:1.0",
+		"1:",
+		"1.0-",
+		"-1",
+		"1.0_1",
+		"1.0-1:2",
+		"1.0-a_b",
+	} {
+		if v, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", s, v)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// each version is lower than the next, following dpkg's deb-version(7)
+	ordered := []string{
+		"~~",
+		"~~a",
+		"~",
+		"0",
+		"0a",
+		"0.1~~",
+		"0.1~",
+		"0.1",
+		"0.1-1",
+		"0.1a",
+		"0.1+",
+		"0.1.1",
+		"0.9",
+		"0.10",
+		"1.0~rc1",
+		"1.0~rc2",
+		"1.0",
+		"1.0-1~bpo1",
+		"1.0-1",
+		"1.0-1+b1",
+		"1.0-1.1",
+		"1.0-2",
+		"1.0-10",
+		"1.0a",
+		"1.0+dfsg",
+		"1.00001",
+		"2",
+		"10",
+		"1:0.1",
+		"1:1.0",
+		"2:0~",
+		"10:0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			got, err := Compare(ordered[i], ordered[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompareEqual(t *testing.T) {
+	for _, test := range []struct{ a, b string }{
+		{"1.0", "0:1.0"},
+		{"1.0", "1.0-0"},
+		{"1.0", "1.00"},
+		{"1.01", "1.1"},
+		{"1.0-01", "1.0-1"},
+		{"0001:1.0", "1:1.0"},
+	} {
+		if c, err := Compare(test.a, test.b); err != nil || c != 0 {
+			t.Errorf("Compare(%q, %q) = %d, %v, want 0", test.a, test.b, c, err)
+		}
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	for _, test := range []struct {
+		a, op, b string
+		want     bool
+	}{
+		{"1.0", "<<", "1.1", true},
+		{"1.0", "lt", "1.0", false},
+		{"1.0", "<=", "1.0", true},
+		{"1.0", "<", "1.0", true},
+		{"1.0", "le", "0.9", false},
+		{"1.0", "=", "1.0-0", true},
+		{"1.0", "eq", "1.0-1", false},
+		{"1.0", "ne", "1.0-1", true},
+		{"1.0", "ne", "0:1.0", false},
+		{"1.0", ">=", "1.0~", true},
+		{"1.0", ">", "1.0", true},
+		{"1.0", "ge", "1:0", false},
+		{"1.0", ">>", "1.0~rc1", true},
+		{"1.0", "gt", "1.0", false},
+	} {
+		got, err := Satisfies(test.a, test.op, test.b)
+		if err != nil {
+			t.Errorf("Satisfies(%q, %q, %q): %v", test.a, test.op, test.b, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Satisfies(%q, %q, %q) = %v, want %v", test.a, test.op, test.b, got, test.want)
+		}
+	}
+
+	for _, test := range []struct{ a, op, b string }{
+		{"1.0", "~", "1.0"},
+		{"1.0", "", "1.0"},
+		{"", "=", "1.0"},
+		{"1.0", "=", "a:1"},
+	} {
+		if _, err := Satisfies(test.a, test.op, test.b); err == nil {
+			t.Errorf("Satisfies(%q, %q, %q) succeeded", test.a, test.op, test.b)
+		}
+	}
+}